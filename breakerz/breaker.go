@@ -0,0 +1,309 @@
+package breakerz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/songlma/gobase/logger"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// OpenErr 熔断打开时拒绝请求返回的错误
+var OpenErr = errors.New("circuit breaker is open")
+
+const windowBuckets = 10
+
+// Config 熔断配置
+// 统计窗口内请求数达到MinRequests后 失败率或慢调用率超过阈值时熔断打开
+// 打开OpenTimeout后进入半开 半开状态下放行HalfOpenRequests个请求 全部成功则关闭 任一失败重新打开
+type Config struct {
+	Window           time.Duration //统计窗口 默认10s
+	MinRequests      int           //窗口内最少请求数 默认20
+	FailureRate      float64       //失败率阈值 默认0.5
+	SlowCallDuration time.Duration //慢调用耗时 为0时不统计慢调用
+	SlowCallRate     float64       //慢调用率阈值 默认1 即不因慢调用熔断
+	OpenTimeout      time.Duration //打开状态持续时间 默认30s
+	HalfOpenRequests int           //半开状态放行的请求数 默认5
+}
+
+// DefaultConfig 10s窗口内至少20个请求 失败率超过50%时熔断30s
+func DefaultConfig() Config {
+	return Config{
+		Window:           10 * time.Second,
+		MinRequests:      20,
+		FailureRate:      0.5,
+		SlowCallRate:     1,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 5,
+	}
+}
+
+func (config Config) withDefaults() Config {
+	defaultConfig := DefaultConfig()
+	if config.Window <= 0 {
+		config.Window = defaultConfig.Window
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultConfig.MinRequests
+	}
+	if config.FailureRate <= 0 {
+		config.FailureRate = defaultConfig.FailureRate
+	}
+	if config.SlowCallRate <= 0 {
+		config.SlowCallRate = defaultConfig.SlowCallRate
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultConfig.OpenTimeout
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = defaultConfig.HalfOpenRequests
+	}
+	return config
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+	slow     int
+}
+
+type Breaker struct {
+	name   string
+	group  string
+	config Config
+	now    func() time.Time
+
+	mu               sync.Mutex
+	state            State
+	generation       uint64
+	openedAt         time.Time
+	buckets          [windowBuckets]bucket
+	halfOpenInflight int
+	halfOpenSuccess  int
+}
+
+func newBreaker(group, name string, config Config) *Breaker {
+	registerMetrics()
+	b := &Breaker{
+		name:   name,
+		group:  group,
+		config: config.withDefaults(),
+		now:    time.Now,
+	}
+	stateGauge.WithLabelValues(group, name).Set(float64(StateClosed))
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refreshState(b.now())
+	return b.state
+}
+
+// Outcome 请求结果
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	//失败 计入失败率 半开时重新打开
+	OutcomeFailure
+	//请求被调用方取消 不计入统计 半开时只释放放行名额
+	OutcomeIgnored
+)
+
+// OutcomeOf failure为true时返回OutcomeFailure
+func OutcomeOf(failure bool) Outcome {
+	if failure {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+/*
+*
+Allow 判断请求是否放行
+放行时返回done 请求结束后必须调用 outcome为本次请求的结果
+熔断打开时返回OpenErr
+*/
+func (b *Breaker) Allow() (done func(outcome Outcome), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.refreshState(now)
+	switch b.state {
+	case StateOpen:
+		return nil, OpenErr
+	case StateHalfOpen:
+		if b.halfOpenInflight+b.halfOpenSuccess >= b.config.HalfOpenRequests {
+			return nil, OpenErr
+		}
+		b.halfOpenInflight++
+	}
+	generation := b.generation
+	start := now
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() {
+			b.record(generation, outcome, b.now().Sub(start))
+		})
+	}, nil
+}
+
+func (b *Breaker) record(generation uint64, outcome Outcome, elapsed time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	//状态已变化 丢弃上一状态的请求结果
+	if generation != b.generation {
+		return
+	}
+	if outcome == OutcomeIgnored {
+		if b.state == StateHalfOpen {
+			b.halfOpenInflight--
+		}
+		return
+	}
+	now := b.now()
+	failure := outcome == OutcomeFailure
+	slow := b.config.SlowCallDuration > 0 && elapsed >= b.config.SlowCallDuration
+	switch b.state {
+	case StateClosed:
+		current := b.bucket(now)
+		current.total++
+		if failure {
+			current.failures++
+		}
+		if slow {
+			current.slow++
+		}
+		total, failures, slowCalls := b.counts(now)
+		if total < b.config.MinRequests {
+			return
+		}
+		if float64(failures)/float64(total) >= b.config.FailureRate ||
+			(b.config.SlowCallDuration > 0 && float64(slowCalls)/float64(total) >= b.config.SlowCallRate) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.halfOpenInflight--
+		if failure || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.halfOpenSuccess++
+		if b.halfOpenSuccess >= b.config.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// refreshState 打开时间超过OpenTimeout后进入半开
+func (b *Breaker) refreshState(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	b.halfOpenInflight = 0
+	b.halfOpenSuccess = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.buckets = [windowBuckets]bucket{}
+	}
+	stateGauge.WithLabelValues(b.group, b.name).Set(float64(state))
+	transitionsTotal.WithLabelValues(b.group, b.name, state.String()).Inc()
+	logger.WithFields(context.Background(), logger.Fields{
+		"type":    "circuit_breaker",
+		"group":   b.group,
+		"breaker": b.name,
+		"from":    from.String(),
+		"to":      state.String(),
+	}).Warnf("circuit breaker %s %s -> %s", b.name, from, state)
+}
+
+// bucketSize Window小于windowBuckets纳秒时 每个桶至少1纳秒
+func (b *Breaker) bucketSize() time.Duration {
+	if size := b.config.Window / windowBuckets; size > 0 {
+		return size
+	}
+	return 1
+}
+
+func (b *Breaker) bucket(now time.Time) *bucket {
+	size := b.bucketSize()
+	start := now.Truncate(size)
+	current := &b.buckets[(start.UnixNano()/int64(size))%windowBuckets]
+	if !current.start.Equal(start) {
+		*current = bucket{start: start}
+	}
+	return current
+}
+
+func (b *Breaker) counts(now time.Time) (total, failures, slow int) {
+	for _, item := range b.buckets {
+		if now.Sub(item.start) < b.config.Window {
+			total += item.total
+			failures += item.failures
+			slow += item.slow
+		}
+	}
+	return total, failures, slow
+}
+
+// Group 按名称懒加载的一组熔断器 例如按host或按路由
+type Group struct {
+	name     string
+	config   Config
+	breakers sync.Map
+}
+
+// NewGroup name 用于指标和日志区分不同的熔断器组
+func NewGroup(name string, config Config) *Group {
+	return &Group{
+		name:   name,
+		config: config,
+	}
+}
+
+func (g *Group) Get(name string) *Breaker {
+	if b, ok := g.breakers.Load(name); ok {
+		return b.(*Breaker)
+	}
+	b, _ := g.breakers.LoadOrStore(name, newBreaker(g.name, name, g.config))
+	return b.(*Breaker)
+}
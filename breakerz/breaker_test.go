@@ -0,0 +1,255 @@
+package breakerz
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestBreaker(now *time.Time) *Breaker {
+	b := NewGroup("test", Config{
+		Window:           time.Second,
+		MinRequests:      4,
+		FailureRate:      0.5,
+		SlowCallDuration: 100 * time.Millisecond,
+		SlowCallRate:     0.5,
+		OpenTimeout:      time.Second,
+		HalfOpenRequests: 2,
+	}).Get("host")
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func call(t *testing.T, b *Breaker, failure bool) {
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("allow err:%v state:%s", err, b.State())
+	}
+	done(OutcomeOf(failure))
+}
+
+func TestBreaker_FailureRate(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	call(t, b, false)
+	call(t, b, true)
+	call(t, b, false)
+	if b.State() != StateClosed {
+		t.Fatalf("want closed got %s", b.State())
+	}
+	call(t, b, true)
+	if b.State() != StateOpen {
+		t.Fatalf("want open got %s", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, OpenErr) {
+		t.Fatalf("want OpenErr got %v", err)
+	}
+
+	//半开 放行2个请求
+	now = now.Add(time.Second)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("want half-open got %s", b.State())
+	}
+	done1, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done2, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Allow(); !errors.Is(err, OpenErr) {
+		t.Fatalf("want OpenErr in half-open got %v", err)
+	}
+	done1(OutcomeSuccess)
+	done2(OutcomeSuccess)
+	if b.State() != StateClosed {
+		t.Fatalf("want closed got %s", b.State())
+	}
+
+	//窗口外的失败不计数
+	call(t, b, true)
+	call(t, b, true)
+	now = now.Add(2 * time.Second)
+	call(t, b, false)
+	call(t, b, false)
+	if b.State() != StateClosed {
+		t.Fatalf("want closed got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenFailure(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		call(t, b, true)
+	}
+	now = now.Add(time.Second)
+	call(t, b, true)
+	if b.State() != StateOpen {
+		t.Fatalf("want open got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenIgnored(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		call(t, b, true)
+	}
+	now = now.Add(time.Second)
+	//被取消的探测请求只释放名额 不关闭熔断
+	for i := 0; i < 3; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(OutcomeIgnored)
+	}
+	if b.State() != StateHalfOpen {
+		t.Fatalf("want half-open got %s", b.State())
+	}
+	call(t, b, false)
+	call(t, b, false)
+	if b.State() != StateClosed {
+		t.Fatalf("want closed got %s", b.State())
+	}
+}
+
+func TestBreaker_TinyWindow(t *testing.T) {
+	b := NewGroup("test", Config{Window: time.Nanosecond, MinRequests: 1}).Get("tiny")
+	call(t, b, true)
+	if b.State() != StateOpen {
+		t.Fatalf("want open got %s", b.State())
+	}
+}
+
+func TestBreaker_SlowCall(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(200 * time.Millisecond)
+		done(OutcomeSuccess)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("want open got %s", b.State())
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	group := NewGroup("grpc_test", Config{MinRequests: 2, FailureRate: 0.5})
+	interceptor := UnaryClientInterceptor(group, GrpcOption{
+		Key: func(cc *grpc.ClientConn, method string) string {
+			return method
+		},
+	})
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "down")
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_ = interceptor(ctx, "/svc/Method", nil, nil, nil, invoker)
+	}
+	if group.Get("/svc/Method").State() != StateOpen {
+		t.Fatal("breaker should be open")
+	}
+
+	fallback := UnaryClientInterceptor(group, GrpcOption{
+		Key: func(cc *grpc.ClientConn, method string) string {
+			return method
+		},
+		Fallback: func(ctx context.Context, method string, req, reply interface{}, err error) error {
+			if status.Code(err) != codes.Unavailable {
+				t.Errorf("fallback want Unavailable got %v", err)
+			}
+			return nil
+		},
+	})
+	if err := fallback(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Errorf("fallback want nil got %v", err)
+	}
+}
+
+type recvClientStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (stream *recvClientStream) RecvMsg(m interface{}) error {
+	return stream.err
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	group := NewGroup("grpc_stream_test", Config{MinRequests: 2, FailureRate: 0.5})
+	interceptor := StreamClientInterceptor(group, GrpcOption{
+		Key: func(cc *grpc.ClientConn, method string) string {
+			return method
+		},
+	})
+	streamErr := status.Error(codes.Unavailable, "down")
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &recvClientStream{err: streamErr}, nil
+	}
+	ctx := context.Background()
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	for i := 0; i < 2; i++ {
+		stream, err := interceptor(ctx, desc, nil, "/svc/Stream", streamer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = stream.RecvMsg(nil)
+	}
+	if group.Get("/svc/Stream").State() != StateOpen {
+		t.Fatal("breaker should be open")
+	}
+	if _, err := interceptor(ctx, desc, nil, "/svc/Stream", streamer); status.Code(err) != codes.Unavailable {
+		t.Fatalf("want Unavailable got %v", err)
+	}
+
+	//调用方取消的流不计为失败
+	streamErr = status.Error(codes.Canceled, "canceled")
+	for i := 0; i < 2; i++ {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		stream, err := interceptor(cancelCtx, desc, nil, "/svc/Cancel", streamer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		_ = stream.RecvMsg(nil)
+	}
+	if group.Get("/svc/Cancel").State() != StateClosed {
+		t.Fatal("canceled streams should not open breaker")
+	}
+}
+
+func TestStreamClientInterceptor_NoGoroutinePerStream(t *testing.T) {
+	group := NewGroup("grpc_stream_goroutine_test", Config{})
+	interceptor := StreamClientInterceptor(group, GrpcOption{
+		Key: func(cc *grpc.ClientConn, method string) string {
+			return method
+		},
+	})
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &recvClientStream{}, nil
+	}
+	before := runtime.NumGoroutine()
+	//不可取消的ctx 且未读到流结束
+	for i := 0; i < 100; i++ {
+		if _, err := interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/svc/Leak", streamer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Fatalf("goroutines grew by %d", n)
+	}
+}
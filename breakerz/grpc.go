@@ -0,0 +1,129 @@
+package breakerz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcOption grpc客户端熔断配置
+type GrpcOption struct {
+	//熔断器名称 默认按 target+method 即按路由熔断
+	Key func(cc *grpc.ClientConn, method string) string
+	//请求被拒绝或失败时调用 返回nil表示降级成功 仅对unary请求生效
+	Fallback func(ctx context.Context, method string, req, reply interface{}, err error) error
+	//判断错误是否计为失败 默认 Unavailable DeadlineExceeded Internal Unknown ResourceExhausted
+	IsFailure func(err error) bool
+}
+
+// GrpcTargetKey 按target熔断
+func GrpcTargetKey(cc *grpc.ClientConn, method string) string {
+	return cc.Target()
+}
+
+// GrpcMethodKey 按target+method熔断
+func GrpcMethodKey(cc *grpc.ClientConn, method string) string {
+	return cc.Target() + method
+}
+
+func grpcIsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// grpcOutcome 调用方取消的请求不计入统计 超时按IsFailure判断
+func grpcOutcome(ctx context.Context, err error, isFailure func(err error) bool) Outcome {
+	if err == nil || err == io.EOF {
+		return OutcomeSuccess
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return OutcomeIgnored
+	}
+	return OutcomeOf(isFailure(err))
+}
+
+// UnaryClientInterceptor grpc客户端熔断拦截器 熔断打开时返回 codes.Unavailable
+func UnaryClientInterceptor(group *Group, option GrpcOption) grpc.UnaryClientInterceptor {
+	if option.Key == nil {
+		option.Key = GrpcMethodKey
+	}
+	if option.IsFailure == nil {
+		option.IsFailure = grpcIsFailure
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		breaker := group.Get(option.Key(cc, method))
+		done, err := breaker.Allow()
+		if err != nil {
+			err = status.Error(codes.Unavailable, err.Error())
+		} else {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			done(grpcOutcome(ctx, err, option.IsFailure))
+		}
+		if err != nil && option.Fallback != nil {
+			return option.Fallback(ctx, method, req, reply, err)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor grpc客户端流熔断拦截器 熔断打开时返回 codes.Unavailable
+// 流结束(RecvMsg返回错误或io.EOF 非服务端流收到响应)或ctx结束时记录结果
+func StreamClientInterceptor(group *Group, option GrpcOption) grpc.StreamClientInterceptor {
+	if option.Key == nil {
+		option.Key = GrpcMethodKey
+	}
+	if option.IsFailure == nil {
+		option.IsFailure = grpcIsFailure
+	}
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		breaker := group.Get(option.Key(cc, method))
+		done, err := breaker.Allow()
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			done(grpcOutcome(ctx, err, option.IsFailure))
+			return nil, err
+		}
+		var once sync.Once
+		finish := func(err error) {
+			once.Do(func() {
+				done(grpcOutcome(ctx, err, option.IsFailure))
+			})
+		}
+		//未读到流结束就放弃的流 在ctx结束时记录 AfterFunc不为每个流常驻goroutine
+		stop := context.AfterFunc(ctx, func() {
+			finish(status.FromContextError(ctx.Err()).Err())
+		})
+		return &breakerClientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: func(err error) {
+			stop()
+			finish(err)
+		}}, nil
+	}
+}
+
+type breakerClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(err error)
+}
+
+func (stream *breakerClientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	if err != nil || !stream.serverStreams {
+		stream.finish(err)
+	}
+	return err
+}
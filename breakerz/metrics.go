@@ -0,0 +1,28 @@
+package breakerz
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open.",
+	}, []string{"group", "breaker"})
+
+	transitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_transitions_total",
+		Help: "Total number of circuit breaker state transitions.",
+	}, []string{"group", "breaker", "state"})
+)
+
+// registerMetrics 创建第一个熔断器时注册 仅import时不修改默认registry
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(stateGauge, transitionsTotal)
+	})
+}
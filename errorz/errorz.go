@@ -178,6 +178,11 @@ func (f fuller) Code() int {
 	return f.code
 }
 
+// Msg 错误信息 不包含文件位置
+func (f fuller) Msg() string {
+	return f.msg
+}
+
 func (f fuller) Alert() string {
 	return f.alert
 }
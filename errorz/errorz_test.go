@@ -95,3 +95,22 @@ func Call() error {
 func SelectSql() error {
 	return sql.ErrNoRows
 }
+
+func TestHTTPStatusOf(t *testing.T) {
+	Register(40401, 404, "不存在")
+	if status := HTTPStatusOf(Wrap(New(40401, "not found"), 50000, "query")); status != 404 {
+		t.Error(status)
+	}
+	if alert := RegisteredAlertOf(Wrap(New(40401, "not found"), 50000, "query")); alert != "不存在" {
+		t.Error(alert)
+	}
+	if status := HTTPStatusOf(New(50001, "business")); status != 200 {
+		t.Error(status)
+	}
+	if status := HTTPStatusOf(sql.ErrNoRows); status != 500 {
+		t.Error(status)
+	}
+	if msg := MsgOf(Wrap(sql.ErrNoRows, 50002, "query user")); msg != "query user" {
+		t.Error(msg)
+	}
+}
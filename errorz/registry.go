@@ -0,0 +1,119 @@
+package errorz
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+type registration struct {
+	httpStatus int
+	alert      string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[int]registration{}
+)
+
+/*
+*
+Register 注册业务码对应的http状态码和默认提示 一般在init中调用
+示例:
+
+	const CodeOrderNotFound = 20404
+
+	func init() {
+		errorz.Register(CodeOrderNotFound, http.StatusNotFound, "订单不存在")
+	}
+*/
+func Register(code int, httpStatus int, alert string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[code] = registration{
+		httpStatus: httpStatus,
+		alert:      alert,
+	}
+}
+
+func lookup(code int) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[code]
+	return r, ok
+}
+
+// eachCode 按错误链顺序遍历业务码 跳过-1
+func eachCode(err error, f func(code int) bool) {
+	for err != nil {
+		var e Error
+		if !errors.As(err, &e) {
+			return
+		}
+		if e.Code() != -1 && !f(e.Code()) {
+			return
+		}
+		err = e.Unwrap()
+	}
+}
+
+/*
+*
+HTTPStatusOf 错误对应的http状态码
+
+	nil 200
+	错误链中首个已注册的业务码 注册的状态码
+	没有业务码(未知错误) 500
+	未注册的业务码 200 由响应中的code区分
+*/
+func HTTPStatusOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	status := 0
+	eachCode(err, func(code int) bool {
+		if r, ok := lookup(code); ok {
+			status = r.httpStatus
+			return false
+		}
+		return true
+	})
+	if status != 0 {
+		return status
+	}
+	if CodeOf(err) == -1 {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// RegisteredAlertOf 错误链中首个已注册业务码的默认提示
+func RegisteredAlertOf(err error) string {
+	alert := ""
+	eachCode(err, func(code int) bool {
+		if r, ok := lookup(code); ok && r.alert != "" {
+			alert = r.alert
+			return false
+		}
+		return true
+	})
+	return alert
+}
+
+// MsgOf 错误链中首个errorz错误的msg 不包含文件位置和cause 非errorz错误返回err.Error()
+func MsgOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	var m interface{ Msg() string }
+	if errors.As(err, &m) {
+		return m.Msg()
+	}
+	return err.Error()
+}
+
+// Registered 业务码注册的http状态码和默认提示
+func Registered(code int) (httpStatus int, alert string, ok bool) {
+	r, ok := lookup(code)
+	return r.httpStatus, r.alert, ok
+}
@@ -97,6 +97,7 @@ func (config GenConfig) GenFile(parentPath, currentDirPath string, recursionDir
 const startWebAppTml = `
 	addr := config.GetString("config.api.web_addr")
 	webConf := web.NewAppConfig(addr)
+	webConf.GrpcAddr = config.GetString("config.api.grpc_port")
 	myapp = web.NewApp(ctx, webConf)
 	if *taskName != "" {
 		myapp.Once(ctx, *taskName)
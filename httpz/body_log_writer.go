@@ -2,6 +2,8 @@ package httpz
 
 import (
 	"bytes"
+	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
@@ -23,21 +25,64 @@ func PutBodyLogWriter(bodyLogWriter *BodyLogWriter) {
 	writerPool.Put(bodyLogWriter)
 }
 
+/*
+*
+BodyLogWriter 记录响应体用于日志
+流式响应(Content-Type为text/event-stream 或调用过Flush)不记录响应体 避免缓存整个响应
+*/
 type BodyLogWriter struct {
 	gin.ResponseWriter
-	bodyBuf *bytes.Buffer
+	bodyBuf   *bytes.Buffer
+	streaming bool
 }
 
 func (w *BodyLogWriter) Init(writer gin.ResponseWriter) {
 	w.bodyBuf.Reset()
+	w.streaming = false
 	w.ResponseWriter = writer
 }
 
-func (w BodyLogWriter) Write(b []byte) (int, error) {
-	w.bodyBuf.Write(b)
+func (w *BodyLogWriter) Write(b []byte) (int, error) {
+	if !w.Streaming() {
+		w.bodyBuf.Write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
 
-func (w BodyLogWriter) BodyString() string {
+func (w *BodyLogWriter) WriteString(s string) (int, error) {
+	if !w.Streaming() {
+		w.bodyBuf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *BodyLogWriter) Flush() {
+	w.setStreaming()
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap 用于http.ResponseController
+func (w *BodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Streaming 是否为流式响应
+func (w *BodyLogWriter) Streaming() bool {
+	if !w.streaming && isStreamContentType(w.Header().Get("Content-Type")) {
+		w.setStreaming()
+	}
+	return w.streaming
+}
+
+func (w *BodyLogWriter) setStreaming() {
+	w.streaming = true
+	w.bodyBuf.Reset()
+}
+
+func (w *BodyLogWriter) BodyString() string {
 	return w.bodyBuf.String()
 }
+
+func isStreamContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/event-stream")
+}
@@ -0,0 +1,79 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/breakerz"
+)
+
+// BreakerOption httpz.Client 熔断配置
+type BreakerOption struct {
+	//熔断器名称 默认BreakerHostKey 即按host熔断
+	Key func(req *http.Request) string
+	//请求被熔断拒绝或失败(IsFailure)时调用 可返回降级的响应
+	//没有error的失败响应(如5xx)关闭响应体后以*StatusError传入
+	Fallback func(req *http.Request, err error) (*http.Response, error)
+	//判断请求是否失败 默认连接错误或5xx
+	IsFailure func(resp *http.Response, err error) bool
+}
+
+// BreakerHostKey 按host熔断
+func BreakerHostKey(req *http.Request) string {
+	return req.URL.Host
+}
+
+// BreakerRouteKey 按 method+host+path 熔断 path中带有id等变量时请自定义Key
+func BreakerRouteKey(req *http.Request) string {
+	return req.Method + " " + req.URL.Host + req.URL.Path
+}
+
+func breakerIsFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+type clientBreaker struct {
+	group  *breakerz.Group
+	option BreakerOption
+}
+
+// outcome 对冲请求或调用方取消的请求不计入统计 超时计为失败
+func (breaker *clientBreaker) outcome(ctx context.Context, resp *http.Response, err error) breakerz.Outcome {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return breakerz.OutcomeIgnored
+	}
+	return breakerz.OutcomeOf(breaker.option.IsFailure(resp, err))
+}
+
+// WithBreaker 设置熔断 每次请求(包括重试)都会经过熔断器 熔断打开时返回breakerz.OpenErr
+func (client *Client) WithBreaker(group *breakerz.Group, option BreakerOption) *Client {
+	if option.Key == nil {
+		option.Key = BreakerHostKey
+	}
+	if option.IsFailure == nil {
+		option.IsFailure = breakerIsFailure
+	}
+	client.breaker = &clientBreaker{
+		group:  group,
+		option: option,
+	}
+	return client
+}
+
+// execute 发送请求 被熔断拒绝或失败时调用熔断降级
+func (client *Client) execute(ctx context.Context, req *http.Request, span opentracing.Span) (*http.Response, error) {
+	response, err := client.doRetry(ctx, req, span)
+	if client.breaker == nil || client.breaker.option.Fallback == nil {
+		return response, err
+	}
+	if err != nil {
+		return client.breaker.option.Fallback(req, err)
+	}
+	if client.breaker.option.IsFailure(response, nil) {
+		_ = response.Body.Close()
+		return client.breaker.option.Fallback(req, &StatusError{StatusCode: response.StatusCode, Method: req.Method, URL: req.URL.String()})
+	}
+	return response, nil
+}
@@ -0,0 +1,120 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songlma/gobase/breakerz"
+)
+
+func TestClient_WithBreaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	group := breakerz.NewGroup("httpz_test", breakerz.Config{MinRequests: 2, FailureRate: 0.5})
+	client := NewClientWithHttpClient(server.Client()).WithBreaker(group, BreakerOption{})
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ctx, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if _, err := client.Get(ctx, server.URL); !errors.Is(err, breakerz.OpenErr) {
+		t.Fatalf("want OpenErr got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("want 2 calls got %d", calls)
+	}
+
+	client = NewClientWithHttpClient(server.Client()).WithBreaker(group, BreakerOption{
+		Fallback: func(req *http.Request, err error) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("fallback")),
+			}, nil
+		},
+	})
+	resp, err := client.Get(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "fallback" {
+		t.Errorf("want fallback got %s", body)
+	}
+}
+
+func TestClient_WithBreaker_FallbackOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var fallbackErr error
+	group := breakerz.NewGroup("httpz_fallback_test", breakerz.Config{MinRequests: 10, FailureRate: 0.5})
+	client := NewClientWithHttpClient(server.Client()).WithBreaker(group, BreakerOption{
+		Fallback: func(req *http.Request, err error) (*http.Response, error) {
+			fallbackErr = err
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("fallback"))}, nil
+		},
+	})
+	//熔断未打开 503响应也走降级
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "fallback" {
+		t.Errorf("want fallback got %s", body)
+	}
+	var statusErr *StatusError
+	if !errors.As(fallbackErr, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("fallback err got %v", fallbackErr)
+	}
+}
+
+func TestClient_WithBreaker_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	group := breakerz.NewGroup("httpz_deadline_test", breakerz.Config{MinRequests: 2, FailureRate: 0.5})
+	client := NewClientWithHttpClient(server.Client()).WithBreaker(group, BreakerOption{})
+	//调用方取消不计为失败
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if _, err := client.Get(ctx, server.URL); err == nil {
+			t.Fatal("canceled request want error")
+		}
+	}
+	if state := group.Get(BreakerHostKey(httptest.NewRequest(http.MethodGet, server.URL, nil))).State(); state != breakerz.StateClosed {
+		t.Fatalf("canceled requests want closed got %s", state)
+	}
+	//超时计为失败
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := client.Get(ctx, server.URL)
+		cancel()
+		if err == nil {
+			t.Fatal("timeout request want error")
+		}
+	}
+	if _, err := client.Get(context.Background(), server.URL); !errors.Is(err, breakerz.OpenErr) {
+		t.Fatalf("timeouts want OpenErr got %v", err)
+	}
+}
@@ -20,6 +20,26 @@ import (
 type Client struct {
 	c           *http.Client
 	Opentracing bool
+	signer      *requestSigner
+	retry       *RetryPolicy
+	hedge       *HedgePolicy
+	breaker     *clientBreaker
+	propagation *propagation
+}
+
+// WithSign 内部请求签名 serviceName为当前服务名 secret为与被调用方约定的密钥
+func (client *Client) WithSign(serviceName, secret string) *Client {
+	client.signer = &requestSigner{
+		serviceName: serviceName,
+		secret:      secret,
+	}
+	return client
+}
+
+// WithTimeout 设置请求超时
+func (client *Client) WithTimeout(timeout time.Duration) *Client {
+	client.c.Timeout = timeout
+	return client
 }
 
 func Get(ctx context.Context, url string) (resp *http.Response, err error) {
@@ -42,17 +62,18 @@ func (client *Client) PostForm(ctx context.Context, url string, data url.Values,
 }
 
 // PostJson
+// 需要解析json响应时 推荐使用 DoJSON PostJSON
 // 示例:
 // resp, err := httpz.PostJson(ctx, url, params, header)
 //
 //	if err != nil {
-//		return nil, errorz.GoErr(err)
+//		return nil, errorz.FromStd(err)
 //	}
 //	defer resp.Body.Close()
 //
-//	body, err := ioutil.ReadAll(resp.Body)
+//	body, err := io.ReadAll(resp.Body)
 //	if err != nil {
-//		return body, errorz.GoErr(err)
+//		return body, errorz.FromStd(err)
 //	}
 func PostJson(ctx context.Context, url string, data interface{}, header http.Header) (resp *http.Response, err error) {
 	return NewDefaultClient().PostJson(ctx, url, data, header)
@@ -105,7 +126,8 @@ func (client *Client) Post(ctx context.Context, url, contentType string, body io
 
 func (client *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	if !client.Opentracing {
-		return client.c.Do(req)
+		client.propagate(ctx, req, nil)
+		return client.execute(ctx, req, nil)
 	}
 	operationName := fmt.Sprintf("HTTP Client %s %s", req.Method, req.URL.String())
 	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
@@ -114,7 +136,8 @@ func (client *Client) Do(ctx context.Context, req *http.Request) (*http.Response
 	ext.SpanKind.Set(span, ext.SpanKindRPCClientEnum)
 	ext.HTTPUrl.Set(span, req.URL.String())
 	ext.Component.Set(span, defaultComponentName)
-	response, err := client.c.Do(req)
+	client.propagate(ctx, req, span)
+	response, err := client.execute(ctx, req, span)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogKV("event", "error")
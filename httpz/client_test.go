@@ -2,11 +2,13 @@ package httpz
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/songlma/gobase/httpz/httptestz"
 	"github.com/songlma/gobase/trace"
 )
 
@@ -27,27 +29,49 @@ func TestName(t *testing.T) {
 		}
 	}()
 
+	stub := httptestz.NewStub(t)
+	stub.Expect(http.MethodPost, "/api/test").
+		RespondJSON(http.StatusOK, map[string]interface{}{"code": 0})
 	ctx := context.Background()
-	resp, err := PostJson(ctx, "http://localhost:8080/api/test", nil, nil)
+	resp, err := NewClientWithHttpClient(stub.HttpClient()).PostJson(ctx, stub.URL+"/api/test", nil, nil)
 	if err != nil {
 		t.Error(err)
 		return
 	}
-	t.Log(resp)
+	_ = resp.Body.Close()
+	t.Log(resp.StatusCode)
 }
 
 func TestClient_PostFile(t *testing.T) {
+	//*.stub.json为手写的fixture 只回放 录制真实接口时使用新的cassette路径和ModeAuto
+	recorder, err := httptestz.NewRecorder("testdata/wechat_media_upload.stub.json", httptestz.ModeReplay, httptestz.ScrubQuery("access_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := recorder.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+	image := httptestz.NewStub(t)
+	image.Expect(http.MethodGet, "/865116d126582ebeba0d799a9dc921aab.jpg").
+		RespondHeader("Content-Type", "image/jpeg").
+		Respond(http.StatusOK, "\xff\xd8\xff\xe0fake jpeg")
+
 	ctx := context.Background()
-	media, err := UploadTempMedia(ctx, "")
+	client := NewClientWithHttpClient(recorder.HttpClient())
+	media, err := UploadTempMedia(ctx, client, image.URL+"/865116d126582ebeba0d799a9dc921aab.jpg")
 	if err != nil {
 		t.Error(err)
 		return
 	}
-	fmt.Println(media)
+	if !strings.Contains(media, `"media_id"`) {
+		t.Errorf("media = %s", media)
+	}
 }
 
-func UploadTempMedia(ctx context.Context, imageUrl string) (string, error) {
-	token := "54_L5SSetZjUU3l_LHwOuwdv_dhwhp3c1QhWnYfRMeJR4joMhlzTGo3QgpPWTTz28KSydlbRNcnNQ2CfaynYd2nfR8mn8L8lYxFUyzz5yEItC4BovNuO3QiUqE7abslXSQWwo-P6ipVs-htla0uNZYeAGAWIH"
+func UploadTempMedia(ctx context.Context, client *Client, imageUrl string) (string, error) {
+	token := os.Getenv("WECHAT_TOKEN")
 	resp, err := http.Get(imageUrl)
 	if err != nil {
 		return "", err
@@ -58,17 +82,17 @@ func UploadTempMedia(ctx context.Context, imageUrl string) (string, error) {
 
 	url := "https://api.weixin.qq.com/cgi-bin/media/upload?access_token=" + token + "&type=image"
 
-	resp, err = PostFile(ctx, url, resp.Body, "media", "865116d126582ebeba0d799a9dc921aab.jpg", nil)
+	resp, err = client.PostFile(ctx, url, resp.Body, "media", "865116d126582ebeba0d799a9dc921aab.jpg", nil)
 
-	if err != nil {
-		return "", err
-	}
-	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
 	return string(body), nil
 }
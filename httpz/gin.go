@@ -9,18 +9,46 @@ import (
 
 type Config struct {
 	k8sReadinessSpan bool
+	disableMetrics   bool
+	trustedProxies   []string
 }
 
+// DefaultTrustedProxies 集群内网段 只有直接对端是集群内的代理(ingress sidecar)时才读取X-Forwarded-For
+var DefaultTrustedProxies = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8", "::1/128", "fc00::/7"}
+
 func DefaultConfig() *Config {
 	return &Config{
 		k8sReadinessSpan: false,
+		disableMetrics:   false,
+		trustedProxies:   DefaultTrustedProxies,
 	}
 }
+
+/*
+*
+TrustedProxies 设置可信代理 ClientIP 从X-Forwarded-For右侧跳过可信代理后取第一个ip
+不传参数时不信任任何代理 ClientIP即RemoteIP
+默认 DefaultTrustedProxies 伪造的X-Forwarded-For左侧地址不会被当作客户端ip
+*/
+func (config *Config) TrustedProxies(proxies ...string) *Config {
+	config.trustedProxies = proxies
+	return config
+}
+
+// DisableMetrics 关闭DefaultGin默认开启的请求指标
+func (config *Config) DisableMetrics() *Config {
+	config.disableMetrics = true
+	return config
+}
+
 func DefaultGin(config *Config, middleware ...gin.HandlerFunc) *gin.Engine {
 	ginEngine := gin.New()
 	if config == nil {
 		config = DefaultConfig()
 	}
+	if err := ginEngine.SetTrustedProxies(config.trustedProxies); err != nil {
+		panic("httpz: DefaultGin trusted proxies " + err.Error())
+	}
 	var options []MWOption
 
 	if !config.k8sReadinessSpan {
@@ -35,6 +63,9 @@ func DefaultGin(config *Config, middleware ...gin.HandlerFunc) *gin.Engine {
 	openTracingGinHandlerFunc := OpenTracingGinHandlerFunc(
 		opentracing.GlobalTracer(), options...,
 	)
+	if !config.disableMetrics {
+		middleware = append(middleware, MetricsGinHandlerFunc())
+	}
 	middleware = append(middleware, openTracingGinHandlerFunc, PanicGinHandlerFunc(), MeshGinHandlerFunc())
 	ginEngine.Use(middleware...)
 	return ginEngine
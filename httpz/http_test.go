@@ -10,24 +10,12 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/songlma/gobase/trace"
+	"github.com/songlma/gobase/trace/tracetest"
 )
 
 func TestAppRequest(t *testing.T) {
 	ctx := context.Background()
-	closer, err := trace.InitJaeger(trace.Config{
-		Service:            "Gov2",
-		LocalAgentHostPort: "localhost:6831",
-		LogSpans:           true,
-	})
-	if err != nil {
-		t.Error("InitJaegerErr:", err)
-	}
-	defer func() {
-		if closer != nil {
-			closer.Close()
-		}
-	}()
+	recorder := tracetest.Install(t)
 
 	handler := GetGinHandler(ctx)
 	params := GetRecentPlayListParams() //
@@ -44,6 +32,14 @@ func TestAppRequest(t *testing.T) {
 		t.Fatalf("ioReadAllErr: %v", err)
 	}
 	t.Log("resBt", string(resBt))
+	recorder.AssertSpan(t, "HTTP POST /2_8/story/recent_play_list").
+		Root().
+		HasTag("http.method", "POST").
+		HasTag("http.status_code", http.StatusBadGateway).
+		HasTag("span.kind", "server").
+		HasTag("component", "net/http").
+		HasError().
+		HasLog("error.kind", http.StatusBadGateway)
 }
 
 func GetGinHandler(ctx context.Context) *gin.Engine {
@@ -0,0 +1,57 @@
+package httptestz
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// Matcher 判断请求是否与录制的请求匹配 req已做过脱敏
+type Matcher func(req RecordedRequest, recorded RecordedRequest) bool
+
+// MatchMethodURL 方法和url(包括参数 参数顺序无关)相同
+func MatchMethodURL(req RecordedRequest, recorded RecordedRequest) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+	u, r := parseURL(req.URL), parseURL(recorded.URL)
+	return u.Scheme == r.Scheme && u.Host == r.Host && u.Path == r.Path &&
+		reflect.DeepEqual(u.Query(), r.Query())
+}
+
+// MatchBody 请求体相同 json请求体按语义比较
+func MatchBody(req RecordedRequest, recorded RecordedRequest) bool {
+	if req.Body == recorded.Body {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(req.Body), &a) != nil || json.Unmarshal([]byte(recorded.Body), &b) != nil {
+		return bytes.Equal([]byte(req.Body), []byte(recorded.Body))
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// MatchHeaders 指定的header相同
+func MatchHeaders(keys ...string) Matcher {
+	return func(req RecordedRequest, recorded RecordedRequest) bool {
+		for _, key := range keys {
+			if http.Header(req.Header).Get(key) != http.Header(recorded.Header).Get(key) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll 所有matcher都匹配
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req RecordedRequest, recorded RecordedRequest) bool {
+		for _, matcher := range matchers {
+			if !matcher(req, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,336 @@
+package httptestz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// ModeAuto cassette文件存在时回放 不存在时录制
+	ModeAuto Mode = iota
+	// ModeRecord 请求真实接口并录制 覆盖已有cassette
+	ModeRecord
+	// ModeReplay 只回放 未匹配到的请求返回错误
+	ModeReplay
+)
+
+// RecordEnv 设置为1时 ModeAuto 强制重新录制
+const RecordEnv = "HTTPTESTZ_RECORD"
+
+const scrubbedValue = "[scrubbed]"
+
+// NoInteractionErr 回放时未找到匹配的请求
+var NoInteractionErr = errors.New("httptestz: no matching interaction in cassette")
+
+var defaultScrubHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Sign", "Timestamp", "Nonce"}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type Cassette struct {
+	//说明 例如标注手写(未录制)的cassette 回放时忽略
+	Comment      string         `json:"comment,omitempty"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type recorderOptions struct {
+	transport    http.RoundTripper
+	matcher      Matcher
+	scrubHeaders []string
+	scrubQuery   []string
+	scrubBody    []func(body string) string
+}
+
+type Option func(*recorderOptions)
+
+// WithTransport 录制时使用的真实Transport 默认http.DefaultTransport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(options *recorderOptions) {
+		options.transport = transport
+	}
+}
+
+// WithMatcher 回放时的请求匹配规则 默认MatchMethodURL
+func WithMatcher(matcher Matcher) Option {
+	return func(options *recorderOptions) {
+		options.matcher = matcher
+	}
+}
+
+// ScrubHeaders 录制时替换的header 追加到默认的 Authorization Cookie Set-Cookie Sign Timestamp Nonce
+func ScrubHeaders(keys ...string) Option {
+	return func(options *recorderOptions) {
+		options.scrubHeaders = append(options.scrubHeaders, keys...)
+	}
+}
+
+// ScrubQuery 录制时替换的url参数 例如access_token
+func ScrubQuery(keys ...string) Option {
+	return func(options *recorderOptions) {
+		options.scrubQuery = append(options.scrubQuery, keys...)
+	}
+}
+
+/*
+*
+ScrubBody 录制时对请求体和响应体调用scrubber 替换其中的敏感数据 回放匹配时同样作用于请求体
+只影响cassette 录制时调用方拿到的仍是原始响应
+*/
+func ScrubBody(scrubber func(body string) string) Option {
+	return func(options *recorderOptions) {
+		options.scrubBody = append(options.scrubBody, scrubber)
+	}
+}
+
+// ScrubJSONKeys 替换请求体和响应体中指定json字段的字符串值 例如access_token openid
+func ScrubJSONKeys(keys ...string) Option {
+	var quoted []string
+	for _, key := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	pattern := regexp.MustCompile(`("(` + strings.Join(quoted, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	return ScrubBody(func(body string) string {
+		return pattern.ReplaceAllString(body, `${1}"`+scrubbedValue+`"`)
+	})
+}
+
+/*
+*
+Recorder 录制回放RoundTripper
+示例:
+
+	recorder, err := httptestz.NewRecorder("testdata/wechat_upload.json", httptestz.ModeAuto, httptestz.ScrubQuery("access_token"), httptestz.ScrubJSONKeys("openid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Stop()
+	client := httpz.NewClientWithHttpClient(recorder.HttpClient())
+*/
+type Recorder struct {
+	path     string
+	mode     Mode
+	options  recorderOptions
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func NewRecorder(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	options := recorderOptions{
+		transport:    http.DefaultTransport,
+		matcher:      MatchMethodURL,
+		scrubHeaders: append([]string{}, defaultScrubHeaders...),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	recorder := &Recorder{
+		path:     path,
+		mode:     mode,
+		options:  options,
+		cassette: &Cassette{},
+	}
+	if mode == ModeAuto {
+		if _, err := os.Stat(path); err == nil && os.Getenv(RecordEnv) != "1" {
+			recorder.mode = ModeReplay
+		} else {
+			recorder.mode = ModeRecord
+		}
+	}
+	if recorder.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, recorder.cassette); err != nil {
+			return nil, fmt.Errorf("httptestz: parse cassette %s: %w", path, err)
+		}
+		recorder.used = make([]bool, len(recorder.cassette.Interactions))
+	}
+	return recorder, nil
+}
+
+// Mode 实际使用的模式 ModeAuto会被解析为ModeRecord或ModeReplay
+func (recorder *Recorder) Mode() Mode {
+	return recorder.mode
+}
+
+// HttpClient 使用Recorder的http.Client 可传给httpz.NewClientWithHttpClient
+func (recorder *Recorder) HttpClient() *http.Client {
+	return &http.Client{Transport: recorder}
+}
+
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if recorder.mode == ModeReplay {
+		return recorder.replay(req, body)
+	}
+	return recorder.record(req, body)
+}
+
+func (recorder *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	scrubbed := recorder.scrubRequest(req, body)
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	//优先使用未回放过的请求 相同请求多次出现时按录制顺序回放
+	for _, reuse := range []bool{false, true} {
+		for i, interaction := range recorder.cassette.Interactions {
+			if recorder.used[i] && !reuse {
+				continue
+			}
+			if recorder.options.matcher(scrubbed, interaction.Request) {
+				recorder.used[i] = true
+				return interaction.Response.toResponse(req), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s", NoInteractionErr, scrubbed.Method, scrubbed.URL)
+}
+
+func (recorder *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	response, err := recorder.options.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(respBody))
+	interaction := &Interaction{
+		Request: recorder.scrubRequest(req, body),
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     recorder.scrubHeader(response.Header),
+			Body:       recorder.scrubBodyString(string(respBody)),
+		},
+	}
+	recorder.mu.Lock()
+	recorder.cassette.Interactions = append(recorder.cassette.Interactions, interaction)
+	recorder.mu.Unlock()
+	return response, nil
+}
+
+// Stop 录制模式下保存cassette
+func (recorder *Recorder) Stop() error {
+	if recorder.mode != ModeRecord {
+		return nil
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	data, err := json.MarshalIndent(recorder.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(recorder.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(recorder.path, data, 0644)
+}
+
+func (recorder *Recorder) scrubRequest(req *http.Request, body []byte) RecordedRequest {
+	u := *req.URL
+	if len(recorder.options.scrubQuery) > 0 {
+		query := u.Query()
+		for _, key := range recorder.options.scrubQuery {
+			if query.Has(key) {
+				query.Set(key, scrubbedValue)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    u.String(),
+		Header: recorder.scrubHeader(req.Header),
+		Body:   recorder.scrubBodyString(string(body)),
+	}
+}
+
+func (recorder *Recorder) scrubBodyString(body string) string {
+	for _, scrubber := range recorder.options.scrubBody {
+		body = scrubber(body)
+	}
+	return body
+}
+
+func (recorder *Recorder) scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, key := range recorder.options.scrubHeaders {
+		if scrubbed.Get(key) != "" {
+			scrubbed.Set(key, scrubbedValue)
+		}
+	}
+	return scrubbed
+}
+
+func (recorded RecordedResponse) toResponse(req *http.Request) *http.Response {
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+}
+
+// readRequestBody 读取请求体 并重置req.Body
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func parseURL(raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return &url.URL{}
+	}
+	return u
+}
@@ -0,0 +1,178 @@
+package httptestz
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_RecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	stub := NewStub(t)
+	stub.Expect(http.MethodPost, "/user/info").
+		WithQuery("access_token", "secret-token").
+		RespondHeader("Set-Cookie", "session=abc").
+		RespondJSON(http.StatusOK, map[string]interface{}{"uid": 1})
+
+	recorder, err := NewRecorder(path, ModeAuto, ScrubQuery("access_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Mode() != ModeRecord {
+		t.Fatalf("mode = %v, want record", recorder.Mode())
+	}
+	recorder.options.transport = stub.HttpClient().Transport
+	url := stub.URL + "/user/info?access_token=secret-token&lang=zh"
+	body := doRequest(t, recorder.HttpClient(), url, "secret-auth")
+	if body != `{"uid":1}` {
+		t.Fatalf("record body = %s", body)
+	}
+	if err = recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-auth", "session=abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	replayer, err := NewRecorder(path, ModeAuto, ScrubQuery("access_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayer.Mode() != ModeReplay {
+		t.Fatalf("mode = %v, want replay", replayer.Mode())
+	}
+	//参数顺序不同 token不同 仍然匹配
+	body = doRequest(t, replayer.HttpClient(), stub.URL+"/user/info?lang=zh&access_token=other", "")
+	if body != `{"uid":1}` {
+		t.Fatalf("replay body = %s", body)
+	}
+
+	_, err = replayer.HttpClient().Get(stub.URL + "/user/list")
+	if !errors.Is(err, NoInteractionErr) {
+		t.Fatalf("err = %v, want NoInteractionErr", err)
+	}
+}
+
+func TestRecorder_ScrubBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	stub := NewStub(t)
+	stub.Expect(http.MethodPost, "/token").
+		RespondJSON(http.StatusOK, map[string]interface{}{"access_token": "secret-json", "expires_in": 7200})
+
+	opts := []Option{ScrubJSONKeys("access_token", "openid"), WithMatcher(MatchAll(MatchMethodURL, MatchBody))}
+	recorder, err := NewRecorder(path, ModeRecord, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.options.transport = stub.HttpClient().Transport
+	resp, err := recorder.HttpClient().Post(stub.URL+"/token", "application/json", strings.NewReader(`{"openid":"secret-openid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "secret-json") {
+		t.Fatalf("record body scrubbed for caller %s", body)
+	}
+	if err = recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-json", "secret-openid"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	replayer, err := NewRecorder(path, ModeReplay, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = replayer.HttpClient().Post(stub.URL+"/token", "application/json", strings.NewReader(`{"openid":"other-openid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != `{"access_token":"[scrubbed]","expires_in":7200}` {
+		t.Fatalf("replay body = %s", body)
+	}
+}
+
+func TestRecorder_MatchBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := Cassette{Interactions: []*Interaction{
+		{
+			Request:  RecordedRequest{Method: http.MethodPost, URL: "http://example.com/a", Body: `{"id":1,"name":"a"}`},
+			Response: RecordedResponse{StatusCode: http.StatusOK, Body: "one"},
+		},
+		{
+			Request:  RecordedRequest{Method: http.MethodPost, URL: "http://example.com/a", Body: `{"id":2}`},
+			Response: RecordedResponse{StatusCode: http.StatusCreated, Body: "two"},
+		},
+	}}
+	data, _ := json.Marshal(cassette)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewRecorder(path, ModeReplay, WithMatcher(MatchAll(MatchMethodURL, MatchBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := recorder.HttpClient().Post("http://example.com/a", "application/json", strings.NewReader(`{"id": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(respBody) != "two" {
+		t.Fatalf("status = %d body = %s", resp.StatusCode, respBody)
+	}
+	resp, err = recorder.HttpClient().Post("http://example.com/a", "application/json", strings.NewReader(`{"name":"a","id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(respBody) != "one" {
+		t.Fatalf("body = %s", respBody)
+	}
+}
+
+func doRequest(t *testing.T, client *http.Client, url, authorization string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"uid":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
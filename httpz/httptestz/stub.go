@@ -0,0 +1,242 @@
+package httptestz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Expectation 桩服务的一个预期请求
+type Expectation struct {
+	method   string
+	path     string
+	matchers []func(req *http.Request, body []byte) bool
+	times    int //<=0 表示不限次数 至少一次
+	calls    int
+	status   int
+	header   http.Header
+	body     []byte
+	handler  http.HandlerFunc
+}
+
+// WithHeader 要求请求header相同
+func (expectation *Expectation) WithHeader(key, value string) *Expectation {
+	return expectation.Match(func(req *http.Request, body []byte) bool {
+		return req.Header.Get(key) == value
+	})
+}
+
+// WithQuery 要求url参数相同
+func (expectation *Expectation) WithQuery(key, value string) *Expectation {
+	return expectation.Match(func(req *http.Request, body []byte) bool {
+		return req.URL.Query().Get(key) == value
+	})
+}
+
+// WithJSONBody 要求json请求体与v语义相同
+func (expectation *Expectation) WithJSONBody(v interface{}) *Expectation {
+	expected, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return expectation.Match(func(req *http.Request, body []byte) bool {
+		return MatchBody(RecordedRequest{Body: string(body)}, RecordedRequest{Body: string(expected)})
+	})
+}
+
+// Match 自定义匹配规则
+func (expectation *Expectation) Match(matcher func(req *http.Request, body []byte) bool) *Expectation {
+	expectation.matchers = append(expectation.matchers, matcher)
+	return expectation
+}
+
+// Times 预期调用次数 默认1次
+func (expectation *Expectation) Times(n int) *Expectation {
+	expectation.times = n
+	return expectation
+}
+
+// AnyTimes 不限调用次数 至少一次
+func (expectation *Expectation) AnyTimes() *Expectation {
+	expectation.times = 0
+	return expectation
+}
+
+// Respond 返回状态码和响应体
+func (expectation *Expectation) Respond(status int, body string) *Expectation {
+	expectation.status = status
+	expectation.body = []byte(body)
+	return expectation
+}
+
+// RespondJSON 返回json响应
+func (expectation *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	expectation.status = status
+	expectation.body = body
+	expectation.header.Set("Content-Type", "application/json")
+	return expectation
+}
+
+// RespondHeader 设置响应header
+func (expectation *Expectation) RespondHeader(key, value string) *Expectation {
+	expectation.header.Set(key, value)
+	return expectation
+}
+
+// RespondFunc 自定义响应 设置后忽略Respond RespondJSON
+func (expectation *Expectation) RespondFunc(handler http.HandlerFunc) *Expectation {
+	expectation.handler = handler
+	return expectation
+}
+
+func (expectation *Expectation) String() string {
+	return expectation.method + " " + expectation.path
+}
+
+func (expectation *Expectation) exhausted() bool {
+	return expectation.times > 0 && expectation.calls >= expectation.times
+}
+
+func (expectation *Expectation) match(req *http.Request, body []byte) bool {
+	if expectation.method != req.Method || expectation.path != req.URL.Path {
+		return false
+	}
+	for _, matcher := range expectation.matchers {
+		if !matcher(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+*
+Stub 带预期的桩服务 测试结束时校验所有预期都被调用
+未匹配的请求会使测试失败并返回501
+示例:
+
+	stub := httptestz.NewStub(t)
+	stub.Expect(http.MethodPost, "/user/info").
+		WithJSONBody(map[string]interface{}{"uid": 1}).
+		RespondJSON(http.StatusOK, web.Result{Code: web.CodeOk, Content: user})
+	client := httpz.NewClientWithHttpClient(stub.HttpClient())
+	resp, err := client.PostJson(ctx, stub.URL+"/user/info", map[string]interface{}{"uid": 1}, nil)
+*/
+type Stub struct {
+	URL          string
+	t            testing.TB
+	server       *httptest.Server
+	mu           sync.Mutex
+	expectations []*Expectation
+}
+
+func NewStub(t testing.TB) *Stub {
+	stub := &Stub{t: t}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serveHTTP))
+	stub.URL = stub.server.URL
+	t.Cleanup(func() {
+		stub.server.Close()
+		stub.Verify()
+	})
+	return stub
+}
+
+// Expect 添加预期请求 同一请求匹配多个预期时按添加顺序使用
+func (stub *Stub) Expect(method, path string) *Expectation {
+	expectation := &Expectation{
+		method: method,
+		path:   path,
+		times:  1,
+		status: http.StatusOK,
+		header: http.Header{},
+	}
+	stub.mu.Lock()
+	stub.expectations = append(stub.expectations, expectation)
+	stub.mu.Unlock()
+	return expectation
+}
+
+// HttpClient 请求桩服务的http.Client 可传给httpz.NewClientWithHttpClient
+func (stub *Stub) HttpClient() *http.Client {
+	return stub.server.Client()
+}
+
+// Verify 校验所有预期的调用次数 NewStub会在测试结束时自动调用
+func (stub *Stub) Verify() {
+	stub.t.Helper()
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	var missing []string
+	for _, expectation := range stub.expectations {
+		if expectation.calls == 0 || (expectation.times > 0 && expectation.calls != expectation.times) {
+			missing = append(missing, fmt.Sprintf("%s called %d times want %s", expectation, expectation.calls, wantTimes(expectation.times)))
+		}
+	}
+	if len(missing) > 0 {
+		stub.t.Errorf("httptestz: unmet expectations:\n%s", strings.Join(missing, "\n"))
+	}
+}
+
+func wantTimes(times int) string {
+	if times <= 0 {
+		return "at least 1"
+	}
+	return fmt.Sprint(times)
+}
+
+func (stub *Stub) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expectation := stub.find(req, body)
+	if expectation == nil {
+		stub.t.Errorf("httptestz: unexpected request %s %s body:%s", req.Method, req.URL, body)
+		http.Error(w, "httptestz: unexpected request", http.StatusNotImplemented)
+		return
+	}
+	if expectation.handler != nil {
+		req.Body = io.NopCloser(strings.NewReader(string(body)))
+		expectation.handler(w, req)
+		return
+	}
+	for key, values := range expectation.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(expectation.status)
+	_, _ = w.Write(expectation.body)
+}
+
+func (stub *Stub) find(req *http.Request, body []byte) *Expectation {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	var exhausted *Expectation
+	for _, expectation := range stub.expectations {
+		if !expectation.match(req, body) {
+			continue
+		}
+		if !expectation.exhausted() {
+			expectation.calls++
+			return expectation
+		}
+		if exhausted == nil {
+			exhausted = expectation
+		}
+	}
+	if exhausted != nil {
+		//超出预期次数 记录调用次数由Verify报告
+		exhausted.calls++
+		return exhausted
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package httptestz
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeT 记录Errorf 用于校验Stub报告的失败
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, format)
+}
+
+func (t *fakeT) Cleanup(func()) {}
+
+func TestStub_Expectations(t *testing.T) {
+	stub := NewStub(t)
+	stub.Expect(http.MethodPost, "/login").
+		WithHeader("Service-Name", "user").
+		WithJSONBody(map[string]interface{}{"name": "a"}).
+		Times(2).
+		RespondJSON(http.StatusOK, map[string]interface{}{"code": 0})
+	stub.Expect(http.MethodGet, "/ping").AnyTimes().Respond(http.StatusOK, "pong")
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPost, stub.URL+"/login", strings.NewReader(`{"name": "a"}`))
+		req.Header.Set("Service-Name", "user")
+		resp, err := stub.HttpClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("status = %d header = %v", resp.StatusCode, resp.Header)
+		}
+	}
+	resp, err := stub.HttpClient().Get(stub.URL + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestStub_Verify(t *testing.T) {
+	ft := &fakeT{TB: t}
+	stub := NewStub(ft)
+	defer stub.server.Close()
+	stub.Expect(http.MethodGet, "/a")
+	stub.Expect(http.MethodGet, "/b").Times(1)
+
+	resp, err := stub.HttpClient().Get(stub.URL + "/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status = %d, want 501", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err = stub.HttpClient().Get(stub.URL + "/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	stub.Verify()
+	//unexpected request + /a未调用 /b调用次数不符
+	if len(ft.errors) != 2 {
+		t.Fatalf("errors = %v", ft.errors)
+	}
+}
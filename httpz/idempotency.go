@@ -0,0 +1,381 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/inner"
+	"github.com/songlma/gobase/logger"
+	"github.com/songlma/gobase/redisz"
+	"github.com/songlma/gobase/trace"
+	"github.com/songlma/gobase/web"
+)
+
+// IdempotentReplayedHeader 响应为缓存的响应时设置为true
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	defaultIdempotencyTTL         = 24 * time.Hour
+	defaultIdempotencyLockTTL     = time.Minute
+	defaultIdempotencyMaxBodySize = 1 << 20
+)
+
+// IdempotencyLockLostErr 保存响应时处理中记录已过期或被其他请求占用 不覆盖已有记录
+var IdempotencyLockLostErr = errors.New("httpz: idempotency pending record expired or replaced")
+
+// IdempotencyRecord 幂等key对应的记录 Done为false表示处理中
+type IdempotencyRecord struct {
+	Done        bool   `json:"done"`
+	Token       string `json:"token,omitempty"`       //处理中记录的持有者 释放时校验
+	Fingerprint string `json:"fingerprint,omitempty"` //请求体sha256 同一key不同请求体时拒绝
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyStore 幂等记录存储
+type IdempotencyStore interface {
+	// Acquire key不存在时写入pending并返回true key已存在时返回已有记录
+	Acquire(ctx context.Context, key string, pending *IdempotencyRecord, ttl time.Duration) (existing *IdempotencyRecord, acquired bool, err error)
+	// Save 保存处理完成的响应 只在key仍为pending时写入 否则返回IdempotencyLockLostErr
+	Save(ctx context.Context, key string, pending, record *IdempotencyRecord, ttl time.Duration) error
+	// Release 处理失败时删除pending 允许调用方重试 key已被其他请求占用时不删除
+	Release(ctx context.Context, key string, pending *IdempotencyRecord) error
+}
+
+// IdempotencyConfig 幂等中间件配置
+type IdempotencyConfig struct {
+	Store   IdempotencyStore
+	TTL     time.Duration //完成的响应保存时间 默认24小时
+	LockTTL time.Duration //处理中记录保存时间 默认1分钟 应大于接口处理超时时间
+	//请求体最大长度 默认1MB 超过时返回413
+	MaxBodySize int64
+	//幂等key 返回空字符串时不做幂等处理 默认读取Idempotency-Key header
+	//回调可使用业务单号 例如 func(ginCtx *gin.Context) string { return ginCtx.Query("out_trade_no") }
+	Key func(ginCtx *gin.Context) string
+	//调用方 不同调用方的相同key互不影响 默认IdempotencyScopeByCaller
+	//回调的调用方ip可能变化 可使用固定值 例如 func(ginCtx *gin.Context) string { return "wechatpay" }
+	Scope func(ginCtx *gin.Context) string
+}
+
+// IdempotencyKeyFromHeader 读取Idempotency-Key header
+func IdempotencyKeyFromHeader(ginCtx *gin.Context) string {
+	return ginCtx.GetHeader(IdempotencyKeyHeader)
+}
+
+// IdempotencyScopeByCaller 内部请求按签名校验后的调用方服务名 其余按contextz中的uid 都没有时按客户端ip
+// 匿名请求不按ip区分时 不同客户端使用相同key会拿到彼此的响应
+func IdempotencyScopeByCaller(ginCtx *gin.Context) string {
+	ctx := ginCtx.Request.Context()
+	if serviceName := inner.GetRequestServiceName(ctx); serviceName != "" {
+		return "svc:" + serviceName
+	}
+	if uid, err := contextz.GetUID(ctx); err == nil && uid != "" {
+		return "uid:" + uid
+	}
+	return "ip:" + ginCtx.ClientIP()
+}
+
+/*
+*
+IdempotencyGinHandlerFunc 幂等中间件
+同一key(按方法+路由+调用方区分)
+
+	处理完成 返回缓存的状态码和响应体 并设置 Idempotent-Replayed: true
+	处理中   返回409
+	请求体不同 返回422
+
+响应状态码>=500或panic时删除记录 允许重试
+存储出错时返回503 避免重复处理
+示例:
+
+	idempotency := httpz.IdempotencyGinHandlerFunc(httpz.IdempotencyConfig{
+		Store: httpz.NewRedisIdempotencyStore(redisPool, "idempotency:"),
+	})
+	callBackGroup.POST("pay/notify", idempotency, callback.PayNotify)
+*/
+func IdempotencyGinHandlerFunc(config IdempotencyConfig) gin.HandlerFunc {
+	if config.TTL <= 0 {
+		config.TTL = defaultIdempotencyTTL
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = defaultIdempotencyLockTTL
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultIdempotencyMaxBodySize
+	}
+	if config.Key == nil {
+		config.Key = IdempotencyKeyFromHeader
+	}
+	if config.Scope == nil {
+		config.Scope = IdempotencyScopeByCaller
+	}
+	return func(ginCtx *gin.Context) {
+		//自定义Key可能读取请求体 先计算指纹 Key之后再重置请求体
+		body, fingerprint, err := requestFingerprint(ginCtx, config.MaxBodySize)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abortResult(ginCtx, http.StatusRequestEntityTooLarge, "request body too large", "请求参数过大")
+				return
+			}
+			abortResult(ginCtx, http.StatusBadRequest, "read request body err", "请求参数错误")
+			return
+		}
+		idempotencyKey := config.Key(ginCtx)
+		resetRequestBody(ginCtx.Request, body)
+		if idempotencyKey == "" {
+			ginCtx.Next()
+			return
+		}
+		ctx := ginCtx.Request.Context()
+		route := ginCtx.FullPath()
+		if route == "" {
+			route = ginCtx.Request.URL.Path
+		}
+		key := ginCtx.Request.Method + ":" + route + ":" + config.Scope(ginCtx) + ":" + idempotencyKey
+		pending := &IdempotencyRecord{
+			Token:       newIdempotencyToken(),
+			Fingerprint: fingerprint,
+		}
+		existing, acquired, err := config.Store.Acquire(ctx, key, pending, config.LockTTL)
+		if err != nil {
+			idempotencyErrorLog(ctx, "Acquire", key, err)
+			abortResult(ginCtx, http.StatusServiceUnavailable, "idempotency store unavailable", "系统繁忙，请稍后再试")
+			return
+		}
+		if !acquired {
+			replayIdempotency(ginCtx, existing, fingerprint)
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: ginCtx.Writer}
+		ginCtx.Writer = writer
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			//panic或5xx 释放key允许重试
+			if err := config.Store.Release(context.WithoutCancel(ctx), key, pending); err != nil {
+				idempotencyErrorLog(ctx, "Release", key, err)
+			}
+		}()
+		ginCtx.Next()
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+		record := &IdempotencyRecord{
+			Done:        true,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := config.Store.Save(context.WithoutCancel(ctx), key, pending, record, config.TTL); err != nil {
+			idempotencyErrorLog(ctx, "Save", key, err)
+		}
+	}
+}
+
+func replayIdempotency(ginCtx *gin.Context, existing *IdempotencyRecord, fingerprint string) {
+	if existing == nil || !existing.Done {
+		abortResult(ginCtx, http.StatusConflict, "request with the same Idempotency-Key is in progress", "请求处理中，请勿重复提交")
+		return
+	}
+	if existing.Fingerprint != fingerprint {
+		abortResult(ginCtx, http.StatusUnprocessableEntity, "Idempotency-Key reused with a different request", "请求参数与之前的请求不一致")
+		return
+	}
+	ginCtx.Header(IdempotentReplayedHeader, "true")
+	contentType := existing.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ginCtx.Data(existing.StatusCode, contentType, existing.Body)
+	ginCtx.Abort()
+}
+
+// abortResult 返回web.Result格式的错误 code为http状态码
+func abortResult(ginCtx *gin.Context, status int, msg, alert string) {
+	ginCtx.AbortWithStatusJSON(status, web.Result{
+		Code:    int64(status),
+		Msg:     msg,
+		Alert:   alert,
+		TraceId: trace.TraceIDFromContext(ginCtx.Request.Context()),
+	})
+}
+
+func idempotencyErrorLog(ctx context.Context, tag, key string, err error) {
+	logger.WithFields(ctx, logger.Fields{"type": "idempotency"}).Errorf("idempotency %s key:%s err:%v", tag, key, err)
+}
+
+// requestFingerprint 读取请求体并计算sha256 读取后重置请求体 请求体超过maxBodySize时返回*http.MaxBytesError
+func requestFingerprint(ginCtx *gin.Context, maxBodySize int64) ([]byte, string, error) {
+	req := ginCtx.Request
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(ginCtx.Writer, req.Body, maxBodySize))
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		resetRequestBody(req, body)
+	}
+	sum := sha256.Sum256(body)
+	return body, hex.EncodeToString(sum[:]), nil
+}
+
+func resetRequestBody(req *http.Request, body []byte) {
+	if body == nil {
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+func newIdempotencyToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// idempotencyWriter 保存响应体用于回放
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap http.ResponseController 通过Unwrap获取底层ResponseWriter
+func (w *idempotencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// KEYS[1] 幂等key ARGV[1] 处理中记录 ARGV[2] 过期时间(毫秒)
+// 返回 {1} 占用成功 {0, 已有记录}
+var idempotencyAcquireScript = redisz.NewScript(1, `
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return {1}
+end
+return {0, redis.call('GET', KEYS[1])}
+`)
+
+// KEYS[1] 幂等key ARGV[1] 处理中记录 ARGV[2] 完成的记录 ARGV[3] 过期时间(毫秒)
+// 返回 1 保存成功 0 处理中记录已过期或被其他请求占用
+var idempotencySaveScript = redisz.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// KEYS[1] 幂等key ARGV[1] 处理中记录
+var idempotencyReleaseScript = redisz.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type redisIdempotencyStore struct {
+	pool   *redisz.Pool
+	prefix string
+}
+
+// NewRedisIdempotencyStore 基于redis的幂等记录存储 记录以json保存
+func NewRedisIdempotencyStore(pool *redisz.Pool, prefix string) IdempotencyStore {
+	return &redisIdempotencyStore{
+		pool:   pool,
+		prefix: prefix,
+	}
+}
+
+func (store *redisIdempotencyStore) Acquire(ctx context.Context, key string, pending *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return nil, false, err
+	}
+	conn := store.pool.GetConn()
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	reply, err := redisz.Values(conn.Eval(ctx, idempotencyAcquireScript, store.prefix+key, value, ttl.Milliseconds()))
+	if err != nil {
+		return nil, false, err
+	}
+	acquired, err := redisz.Int64(reply[0], nil)
+	if err != nil || acquired == 1 {
+		return nil, acquired == 1, err
+	}
+	if len(reply) < 2 {
+		return nil, false, nil
+	}
+	existing, err := redisz.Bytes(reply[1], nil)
+	if err != nil {
+		return nil, false, err
+	}
+	record := &IdempotencyRecord{}
+	if err = json.Unmarshal(existing, record); err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func (store *redisIdempotencyStore) Save(ctx context.Context, key string, pending, record *IdempotencyRecord, ttl time.Duration) error {
+	pendingValue, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	conn := store.pool.GetConn()
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	saved, err := redisz.Int64(conn.Eval(ctx, idempotencySaveScript, store.prefix+key, pendingValue, value, ttl.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	if saved == 0 {
+		return IdempotencyLockLostErr
+	}
+	return nil
+}
+
+func (store *redisIdempotencyStore) Release(ctx context.Context, key string, pending *IdempotencyRecord) error {
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	conn := store.pool.GetConn()
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	_, err = conn.Eval(ctx, idempotencyReleaseScript, store.prefix+key, value)
+	return err
+}
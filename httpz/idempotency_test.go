@@ -0,0 +1,204 @@
+package httpz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/inner"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func (store *memoryIdempotencyStore) Acquire(ctx context.Context, key string, pending *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if existing, ok := store.records[key]; ok {
+		return &existing, false, nil
+	}
+	store.records[key] = *pending
+	return nil, true, nil
+}
+
+func (store *memoryIdempotencyStore) Save(ctx context.Context, key string, pending, record *IdempotencyRecord, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if existing, ok := store.records[key]; !ok || existing.Token != pending.Token {
+		return IdempotencyLockLostErr
+	}
+	store.records[key] = *record
+	return nil
+}
+
+func (store *memoryIdempotencyStore) Release(ctx context.Context, key string, pending *IdempotencyRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if existing, ok := store.records[key]; ok && existing.Token == pending.Token {
+		delete(store.records, key)
+	}
+	return nil
+}
+
+func TestIdempotencyGinHandlerFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: map[string]IdempotencyRecord{}}
+	var calls int
+	release := make(chan struct{})
+	engine := gin.New()
+	engine.POST("/order", IdempotencyGinHandlerFunc(IdempotencyConfig{Store: store}), func(ginCtx *gin.Context) {
+		calls++
+		if ginCtx.Query("block") != "" {
+			<-release
+		}
+		if ginCtx.Query("fail") != "" {
+			ginCtx.String(http.StatusInternalServerError, "fail")
+			return
+		}
+		ginCtx.JSON(http.StatusCreated, gin.H{"order": calls})
+	})
+	do := func(url, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("/order", "k1", `{"a":1}`)
+	replay := do("/order", "k1", `{"a":1}`)
+	if calls != 1 || replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() || replay.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("calls = %d replay = %d %s", calls, replay.Code, replay.Body)
+	}
+	if w := do("/order", "k1", `{"a":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("mismatch status = %d", w.Code)
+	}
+	if w := do("/order", "", `{"a":1}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("no key status = %d calls = %d", w.Code, calls)
+	}
+
+	//5xx 释放key 重试时重新处理
+	if w := do("/order?fail=1", "k2", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("fail status = %d", w.Code)
+	}
+	if w := do("/order", "k2", ""); w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("retry status = %d header = %v", w.Code, w.Header())
+	}
+
+	//并发的重复请求返回409
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do("/order?block=1", "k3", "")
+	}()
+	for {
+		store.mu.Lock()
+		_, ok := store.records["POST:/order:ip:192.0.2.1:k3"]
+		store.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if w := do("/order?block=1", "k3", ""); w.Code != http.StatusConflict {
+		t.Fatalf("concurrent status = %d", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("blocked status = %d", w.Code)
+	}
+}
+
+func TestIdempotencyGinHandlerFunc_ScopeAndBodyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: map[string]IdempotencyRecord{}}
+	var bodies []string
+	engine := gin.New()
+	engine.POST("/notify", func(ginCtx *gin.Context) {
+		caller := ginCtx.GetHeader("Test-Caller")
+		ginCtx.Request = ginCtx.Request.WithContext(inner.WithRequestServiceName(ginCtx.Request.Context(), caller))
+	}, IdempotencyGinHandlerFunc(IdempotencyConfig{
+		Store: store,
+		//业务单号在请求体中 Key会读取请求体
+		Key: func(ginCtx *gin.Context) string {
+			var req struct {
+				TradeNo string `json:"trade_no"`
+			}
+			_ = ginCtx.ShouldBindJSON(&req)
+			return req.TradeNo
+		},
+	}), func(ginCtx *gin.Context) {
+		body, _ := ginCtx.GetRawData()
+		bodies = append(bodies, string(body))
+		_ = http.NewResponseController(ginCtx.Writer).Flush()
+		ginCtx.String(http.StatusOK, "ok")
+	})
+	do := func(caller, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+		req.Header.Set("Test-Caller", caller)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"trade_no":"t1"}`
+	if w := do("poster", body); w.Code != http.StatusOK || !w.Flushed {
+		t.Fatalf("status = %d flushed = %v", w.Code, w.Flushed)
+	}
+	if w := do("poster", body); w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("same caller want replay got %d %v", w.Code, w.Header())
+	}
+	//其他调用方的相同key不受影响
+	if w := do("order", body); w.Code != http.StatusOK || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("other caller status = %d header = %v", w.Code, w.Header())
+	}
+	if len(bodies) != 2 || bodies[0] != body || bodies[1] != body {
+		t.Fatalf("handler bodies = %q", bodies)
+	}
+	if _, ok := store.records["POST:/notify:svc:poster:t1"]; !ok {
+		t.Fatalf("records = %v", store.records)
+	}
+}
+
+func TestIdempotencyGinHandlerFunc_LockLostAndBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: map[string]IdempotencyRecord{}}
+	engine := gin.New()
+	engine.POST("/order", IdempotencyGinHandlerFunc(IdempotencyConfig{Store: store, MaxBodySize: 16}), func(ginCtx *gin.Context) {
+		if ginCtx.Query("expire") != "" {
+			//处理超过LockTTL 其他请求占用了key
+			store.mu.Lock()
+			store.records["POST:/order:ip:192.0.2.1:k1"] = IdempotencyRecord{Token: "other"}
+			store.mu.Unlock()
+		}
+		ginCtx.String(http.StatusOK, "ok")
+	})
+	do := func(url, remoteAddr, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("/order?expire=1", "192.0.2.1:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if record := store.records["POST:/order:ip:192.0.2.1:k1"]; record.Done || record.Token != "other" {
+		t.Fatalf("save overwrote other request %+v", record)
+	}
+	//匿名请求按ip区分
+	if w := do("/order", "192.0.2.2:1234", ""); w.Code != http.StatusOK || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("other ip status = %d header = %v", w.Code, w.Header())
+	}
+	if w := do("/order", "192.0.2.3:1234", strings.Repeat("a", 17)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large body status = %d", w.Code)
+	}
+}
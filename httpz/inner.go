@@ -1,11 +1,11 @@
 package httpz
 
 import (
-	"encoding/json"
 	"io/ioutil"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/web"
 )
 
 func GetInnerRequestParams(ginCtx *gin.Context) (body []byte, err error) {
@@ -32,35 +32,9 @@ type Request struct {
 
 /*
 *
-绑定参数
+绑定参数并校验 与web.ShouldBindBodyWith一致 支持V2请求格式
+校验失败返回 *web.ValidationError
 */
 func ShouldBindBodyWith(ginContext *gin.Context, obj interface{}) error {
-	body, errz := GetInnerRequestParams(ginContext)
-	if errz != nil {
-		return errz
-	}
-	var object json.RawMessage
-	var res = Request{
-		Params: &object,
-	}
-	err := json.Unmarshal(body, &res)
-	if err != nil {
-		return err
-	}
-	if res.Version == "" {
-
-	}
-	//兼容老版本请求
-	switch res.Version {
-	case "V2":
-		if err = json.Unmarshal(object, obj); err != nil {
-			return err
-		}
-	default:
-		err = json.Unmarshal(body, obj)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return web.ShouldBindBodyWith(ginContext, obj)
 }
@@ -2,14 +2,17 @@ package httpz
 
 import (
 	"context"
+	"crypto/hmac"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/inner"
 	"github.com/songlma/gobase/logger"
-	"github.com/songlma/gobase/web"
+	"github.com/songlma/gobase/trace"
 )
 
 const sign = "sign"
@@ -17,11 +20,23 @@ const timestamp = "timestamp"
 const serviceName = "Service-Name"
 const traceId = "Trace-ID"
 
+// ServiceNameKey 内部请求调用方服务名header
+const ServiceNameKey = serviceName
+
+// TraceIdKey 内部请求traceId header
+const TraceIdKey = traceId
+
 /*
 *
 内部请求
+校验HMAC-SHA256签名 签名规则见 Sign
+signConfig.Secrets 中不存在的Service-Name直接拒绝
+signConfig为nil时panic 避免未配置签名的路由在运行时才出错
 */
-func InterSignGinHandlerFunc() gin.HandlerFunc {
+func InterSignGinHandlerFunc(signConfig *SignConfig) gin.HandlerFunc {
+	if signConfig == nil {
+		panic("httpz: InterSignGinHandlerFunc signConfig is nil")
+	}
 	return func(ginCtx *gin.Context) {
 		ContentType := ginCtx.Request.Header.Get("Content-Type")
 		if !strings.Contains(ContentType, "application/json") {
@@ -29,13 +44,57 @@ func InterSignGinHandlerFunc() gin.HandlerFunc {
 			ginCtx.Abort()
 			return
 		}
+		ctx := ginCtx.Request.Context()
 		signHeader := ginCtx.Request.Header.Get(sign)
 		timestampHeader := ginCtx.Request.Header.Get(timestamp)
-		if signHeader == "" || timestampHeader == "" || timestampHeader != signHeader {
+		nonceHeader := ginCtx.Request.Header.Get(nonce)
+		serviceNameHeader := ginCtx.Request.Header.Get(serviceName)
+		secret, ok := signConfig.Secrets[serviceNameHeader]
+		if signHeader == "" || timestampHeader == "" || nonceHeader == "" || !ok || secret == "" {
 			ginCtx.JSON(http.StatusBadRequest, "bad request sign")
 			ginCtx.Abort()
 			return
 		}
+		requestTime, err := strconv.ParseInt(timestampHeader, 10, 64)
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, "bad request timestamp")
+			ginCtx.Abort()
+			return
+		}
+		skew := time.Since(time.Unix(requestTime, 0))
+		if skew > signConfig.skew() || skew < -signConfig.skew() {
+			ginCtx.JSON(http.StatusBadRequest, "bad request timestamp")
+			ginCtx.Abort()
+			return
+		}
+		body, err := GetInnerRequestParams(ginCtx)
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, "bad request body")
+			ginCtx.Abort()
+			return
+		}
+		expected := Sign(secret, ginCtx.Request.Method, SignPath(ginCtx.Request.URL), timestampHeader, nonceHeader, serviceNameHeader, body)
+		if !hmac.Equal([]byte(expected), []byte(signHeader)) {
+			ginCtx.JSON(http.StatusBadRequest, "bad request sign")
+			ginCtx.Abort()
+			return
+		}
+		if signConfig.Nonce != nil {
+			claimed, err := signConfig.Nonce.Claim(ctx, serviceNameHeader+":"+nonceHeader, signConfig.nonceTTL())
+			if err != nil {
+				logger.Error(ctx, "InterSignGinHandlerFunc nonce claim err:", err)
+				ginCtx.JSON(http.StatusInternalServerError, "nonce check err")
+				ginCtx.Abort()
+				return
+			}
+			if !claimed {
+				ginCtx.JSON(http.StatusBadRequest, "bad request nonce")
+				ginCtx.Abort()
+				return
+			}
+		}
+		//保存校验后的调用方 供幂等等中间件区分调用方
+		ginCtx.Request = ginCtx.Request.WithContext(inner.WithRequestServiceName(ctx, serviceNameHeader))
 		ginCtx.Next()
 	}
 }
@@ -51,11 +110,9 @@ func InterRequestLogGinHandlerFunc() gin.HandlerFunc {
 		logWriter.Init(ginCtx.Writer)
 		ginCtx.Writer = logWriter
 		serviceNameHeader := ginCtx.Request.Header.Get(serviceName)
-		traceIdHeader := ginCtx.Request.Header.Get(traceId)
-		//兼容老版本
-		if traceIdHeader == "" {
-			traceIdHeader = ginCtx.Request.Header.Get(web.CorralIdKey)
-		}
+		//按trace.GetPropagator()的顺序读取 默认包括Trace-ID和兼容老版本的CorralId
+		tc, _ := trace.ExtractHTTP(ginCtx.Request.Header)
+		traceIdHeader := tc.TraceId
 		ginCtx.Next()
 		ctx := ginCtx.Request.Context()
 		path := ginCtx.Request.URL.Path
@@ -71,6 +128,14 @@ func InterRequestLogGinHandlerFunc() gin.HandlerFunc {
 			"status":       ginCtx.Writer.Status(),
 			traceId:        traceIdHeader,
 		}
+		//流式响应只记录元数据
+		if logWriter.Streaming() {
+			fields["stream"] = true
+			fields["size"] = ginCtx.Writer.Size()
+			logger.WithFields(ctx, fields).Info("[stream]")
+			PutBodyLogWriter(logWriter)
+			return
+		}
 		logger.WithFields(ctx, fields).Info(strings.Trim(logWriter.BodyString(), "\n"))
 		PutBodyLogWriter(logWriter)
 	}
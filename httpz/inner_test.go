@@ -0,0 +1,38 @@
+package httpz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/web"
+)
+
+func TestShouldBindBodyWith(t *testing.T) {
+	type createReq struct {
+		Name string `json:"name" binding:"required"`
+	}
+	bind := func(body string) (createReq, error) {
+		gin.SetMode(gin.TestMode)
+		ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ginCtx.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		ginCtx.Request.Header.Set("Content-Type", "application/json")
+		var req createReq
+		err := ShouldBindBodyWith(ginCtx, &req)
+		return req, err
+	}
+	if req, err := bind(`{"version":"V2","params":{"name":"tom"}}`); err != nil || req.Name != "tom" {
+		t.Fatalf("v2 req = %+v err = %v", req, err)
+	}
+	var validationErr *web.ValidationError
+	if _, err := bind(`{"name":""}`); !errors.As(err, &validationErr) || validationErr.Violations[0].Field != "name" {
+		t.Fatalf("validation err = %v", err)
+	}
+	if _, err := bind(`{"name":`); errorz.CodeOf(err) != web.CodeParamsErr {
+		t.Fatalf("syntax err = %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/web"
+)
+
+// DefaultMaxResponseBody DoJSON 默认最大响应体 10M
+const DefaultMaxResponseBody int64 = 10 << 20
+
+// 错误信息中保留的响应体长度
+const bodySnippetSize = 512
+
+var BodyTooLargeErr = errors.New("httpz: response body too large")
+
+/*
+*
+CodeUpstreamErr 下游返回非2xx且响应不是web.Result时的errorz code 注册为http 502
+不使用下游的http状态码作为code 避免与CodeParamsErr等业务码冲突 下游状态码通过*StatusError获取
+*/
+const CodeUpstreamErr = http.StatusBadGateway
+
+const upstreamErrAlert = "服务暂时不可用，请稍后再试"
+
+func init() {
+	errorz.Register(CodeUpstreamErr, http.StatusBadGateway, upstreamErrAlert)
+}
+
+// StatusError 非2xx响应 可通过errors.As获取
+type StatusError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Body       string //响应体片段
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpz: %s %s status %d body:%s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+type jsonOptions struct {
+	header   http.Header
+	maxBody  int64
+	envelope bool
+}
+
+// JSONOption DoJSON 可选项
+type JSONOption func(*jsonOptions)
+
+// JSONHeader 设置请求header 其中Host会设置为请求的Host
+func JSONHeader(header http.Header) JSONOption {
+	return func(options *jsonOptions) {
+		options.header = header
+	}
+}
+
+// JSONMaxBodySize 设置最大响应体 超过时返回BodyTooLargeErr
+func JSONMaxBodySize(size int64) JSONOption {
+	return func(options *jsonOptions) {
+		options.maxBody = size
+	}
+}
+
+// JSONResultEnvelope 响应为web.Result格式(内部服务) 解析content到Resp code!=0时返回errorz.Error
+func JSONResultEnvelope() JSONOption {
+	return func(options *jsonOptions) {
+		options.envelope = true
+	}
+}
+
+/*
+*
+DoJSON 发送json请求并解析json响应
+GET HEAD 请求不发送请求体
+示例:
+
+	user, err := httpz.DoJSON[GetUserReq, User](ctx, client, http.MethodPost, url, GetUserReq{Uid: uid}, httpz.JSONResultEnvelope())
+	if err != nil {
+		return nil, err
+	}
+
+err
+
+	请求失败 errorz.FromStd 包装的原始错误
+	非2xx errorz.Error code为CodeUpstreamErr errors.As 可获取 *StatusError
+	JSONResultEnvelope 且 code!=0 errorz.Error code和alert为响应中的code和alert 非2xx时同样优先解析
+*/
+func DoJSON[Req, Resp any](ctx context.Context, client *Client, method, url string, req Req, opts ...JSONOption) (Resp, error) {
+	var resp Resp
+	options := jsonOptions{
+		maxBody: DefaultMaxResponseBody,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var body io.Reader
+	if method != http.MethodGet && method != http.MethodHead {
+		reqBytes, err := json.Marshal(req)
+		if err != nil {
+			return resp, errorz.FromStd(err)
+		}
+		body = bytes.NewReader(reqBytes)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return resp, errorz.FromStd(err)
+	}
+	for key := range options.header {
+		httpReq.Header.Set(key, options.header.Get(key))
+	}
+	if host := options.header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpResp, err := client.Do(ctx, httpReq)
+	if err != nil {
+		return resp, errorz.FromStd(err)
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+	respBytes, err := io.ReadAll(io.LimitReader(httpResp.Body, options.maxBody+1))
+	if err != nil {
+		return resp, errorz.FromStd(err)
+	}
+	if int64(len(respBytes)) > options.maxBody {
+		return resp, errorz.Wrap(BodyTooLargeErr, -1, fmt.Sprintf("%s %s response body exceeds %d bytes", method, url, options.maxBody))
+	}
+	if options.envelope {
+		content, err := ResultContent(httpResp.StatusCode, respBytes, method, url)
+		if err != nil || content == nil {
+			return resp, err
+		}
+		if err = json.Unmarshal(content, &resp); err != nil {
+			return resp, errorz.Wrap(err, -1, fmt.Sprintf("%s %s unmarshal content err body:%s", method, url, bodySnippet(respBytes)))
+		}
+		return resp, nil
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return resp, upstreamError(httpResp.StatusCode, respBytes, method, url)
+	}
+	if len(bytes.TrimSpace(respBytes)) == 0 {
+		return resp, nil
+	}
+	if err = json.Unmarshal(respBytes, &resp); err != nil {
+		return resp, errorz.Wrap(err, -1, fmt.Sprintf("%s %s unmarshal response err body:%s", method, url, bodySnippet(respBytes)))
+	}
+	return resp, nil
+}
+
+// GetJSON GET请求并解析json响应
+func GetJSON[Resp any](ctx context.Context, client *Client, url string, opts ...JSONOption) (Resp, error) {
+	return DoJSON[struct{}, Resp](ctx, client, http.MethodGet, url, struct{}{}, opts...)
+}
+
+// PostJSON POST json请求并解析json响应
+func PostJSON[Req, Resp any](ctx context.Context, client *Client, url string, req Req, opts ...JSONOption) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, client, http.MethodPost, url, req, opts...)
+}
+
+/*
+*
+ResultContent 解析web.Result响应 返回content 响应为空或content为null时返回nil
+DoJSON(JSONResultEnvelope)和rpcz.InvokeHTTP使用
+err
+
+	code!=0 errorz.Error code和alert为响应中的code和alert 非2xx时同样优先解析
+	非2xx且不是web.Result errorz.Error code为CodeUpstreamErr errors.As 可获取 *StatusError
+	2xx且不是web.Result errorz.Error code为-1
+*/
+func ResultContent(statusCode int, respBytes []byte, method, url string) (json.RawMessage, error) {
+	if statusCode < 200 || statusCode > 299 {
+		//内部服务的业务错误可能带非2xx状态码 优先返回响应中的code
+		if errz := resultError(respBytes); errz != nil {
+			return nil, errz
+		}
+		return nil, upstreamError(statusCode, respBytes, method, url)
+	}
+	if len(bytes.TrimSpace(respBytes)) == 0 {
+		return nil, nil
+	}
+	var content json.RawMessage
+	result := web.Result{
+		Content: &content,
+	}
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, errorz.Wrap(err, -1, fmt.Sprintf("%s %s unmarshal result err body:%s", method, url, bodySnippet(respBytes)))
+	}
+	if result.Code != web.CodeOk {
+		return nil, errorz.New(int(result.Code), result.Msg, errorz.WithAlert(result.Alert))
+	}
+	if len(content) == 0 || string(content) == "null" {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// upstreamError 非2xx响应 code为CodeUpstreamErr 原始状态码保存在*StatusError中
+func upstreamError(statusCode int, respBytes []byte, method, url string) errorz.Error {
+	statusErr := &StatusError{
+		StatusCode: statusCode,
+		Method:     method,
+		URL:        url,
+		Body:       bodySnippet(respBytes),
+	}
+	return errorz.Wrap(statusErr, CodeUpstreamErr, statusErr.Error())
+}
+
+// resultError 响应为web.Result且code!=0时返回对应的errorz.Error 否则返回nil
+func resultError(respBytes []byte) errorz.Error {
+	var result web.Result
+	if err := json.Unmarshal(respBytes, &result); err != nil || result.Code == web.CodeOk {
+		return nil
+	}
+	return errorz.New(int(result.Code), result.Msg, errorz.WithAlert(result.Alert))
+}
+
+func bodySnippet(body []byte) string {
+	if len(body) > bodySnippetSize {
+		return string(body[:bodySnippetSize]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,121 @@
+package httpz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/web"
+)
+
+type jsonTestReq struct {
+	Uid string `json:"uid"`
+}
+
+type jsonTestResp struct {
+	Name string `json:"name"`
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			_, _ = w.Write([]byte(`{"name":"tom"}`))
+		case "/result":
+			_, _ = w.Write([]byte(`{"code":0,"msg":"success","content":{"name":"jerry"}}`))
+		case "/result_err":
+			_, _ = w.Write([]byte(`{"code":1001,"msg":"user not found","alert":"用户不存在","content":null}`))
+		case "/result_400":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"msg":"uid required","alert":"参数错误","content":null}`))
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client := NewClientWithHttpClient(server.Client())
+
+	resp, err := PostJSON[jsonTestReq, jsonTestResp](ctx, client, server.URL+"/user", jsonTestReq{Uid: "1"})
+	if err != nil || resp.Name != "tom" {
+		t.Errorf("want tom got %+v %v", resp, err)
+	}
+
+	resp, err = PostJSON[jsonTestReq, jsonTestResp](ctx, client, server.URL+"/result", jsonTestReq{Uid: "1"}, JSONResultEnvelope())
+	if err != nil || resp.Name != "jerry" {
+		t.Errorf("want jerry got %+v %v", resp, err)
+	}
+
+	_, err = PostJSON[jsonTestReq, jsonTestResp](ctx, client, server.URL+"/result_err", jsonTestReq{Uid: "1"}, JSONResultEnvelope())
+	if errorz.CodeOf(err) != 1001 || errorz.AlertOf(err) != "用户不存在" {
+		t.Errorf("want code 1001 got %v", err)
+	}
+
+	_, err = PostJSON[jsonTestReq, jsonTestResp](ctx, client, server.URL+"/result_400", jsonTestReq{}, JSONResultEnvelope())
+	if errorz.CodeOf(err) != 400 || errorz.AlertOf(err) != "参数错误" || errors.As(err, new(*StatusError)) {
+		t.Errorf("non-2xx result want code 400 alert got %v", err)
+	}
+
+	_, err = GetJSON[jsonTestResp](ctx, client, server.URL+"/missing", JSONResultEnvelope())
+	if !errors.As(err, new(*StatusError)) {
+		t.Errorf("non-result body want StatusError got %v", err)
+	}
+
+	_, err = GetJSON[jsonTestResp](ctx, client, server.URL+"/missing")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || statusErr.Body != "not found" {
+		t.Errorf("want StatusError got %v", err)
+	}
+	if errorz.CodeOf(err) != CodeUpstreamErr {
+		t.Errorf("want code %d got %d", CodeUpstreamErr, errorz.CodeOf(err))
+	}
+
+	_, err = GetJSON[jsonTestResp](ctx, client, server.URL+"/large", JSONMaxBodySize(10))
+	if !errors.Is(err, BodyTooLargeErr) {
+		t.Errorf("want BodyTooLargeErr got %v", err)
+	}
+}
+
+// 下游非web.Result的4xx/5xx 经web.Respond输出为502 不与业务码冲突
+func TestDoJSON_UpstreamStatusRespond(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/400":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad request"))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("<html>503</html>"))
+		}
+	}))
+	defer server.Close()
+	client := NewClientWithHttpClient(server.Client())
+	for _, path := range []string{"/400", "/503"} {
+		for _, opts := range [][]JSONOption{nil, {JSONResultEnvelope()}} {
+			_, err := GetJSON[jsonTestResp](context.Background(), client, server.URL+path, opts...)
+			recorder := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(recorder)
+			ginCtx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			web.Respond(ginCtx, nil, err)
+			if recorder.Code != http.StatusBadGateway {
+				t.Errorf("%s want http 502 got %d", path, recorder.Code)
+			}
+			var result web.Result
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Code != CodeUpstreamErr || result.Alert != upstreamErrAlert {
+				t.Errorf("%s want code %d got %+v", path, CodeUpstreamErr, result)
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package httpz
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/songlma/gobase/logger"
+)
+
+const defaultLogBodySize = 1024
+
+var defaultRedactKeys = []string{"password", "passwd", "token", "access_token", "secret", "sign", "authorization"}
+
+var (
+	clientMetricsOnce sync.Once
+
+	clientRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Latency of outgoing HTTP requests made by httpz.Client.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host", "route", "method", "status"})
+)
+
+func registerClientMetrics() {
+	clientMetricsOnce.Do(func() {
+		prometheus.MustRegister(clientRequestDuration)
+	})
+}
+
+type logTransportOptions struct {
+	maxBodySize int
+	redactKeys  []string
+	routeFunc   func(req *http.Request) string
+}
+
+// LogOption 出站请求日志可选项
+type LogOption func(*logTransportOptions)
+
+// LogMaxBodySize 日志中请求体和响应体的最大长度 默认1024
+func LogMaxBodySize(size int) LogOption {
+	return func(options *logTransportOptions) {
+		options.maxBodySize = size
+	}
+}
+
+// LogRedactKeys 需要脱敏的字段 会替换默认字段
+// 对json字段 form字段 url参数生效
+func LogRedactKeys(keys ...string) LogOption {
+	return func(options *logTransportOptions) {
+		options.redactKeys = keys
+	}
+}
+
+// LogRouteFunc 指标中的路由模板 默认将path中的数字 uuid等段替换为:id
+func LogRouteFunc(f func(req *http.Request) string) LogOption {
+	return func(options *logTransportOptions) {
+		options.routeFunc = f
+	}
+}
+
+type logTransport struct {
+	next       http.RoundTripper
+	options    logTransportOptions
+	redactJSON *regexp.Regexp
+	redactForm *regexp.Regexp
+}
+
+/*
+*
+NewLogTransport 出站请求日志
+输出 type:outgoing 日志 并记录 http_client_request_duration_seconds 指标
+
+	host   请求host
+	route  路由模板
+	method 请求方法
+	status 状态码分类 请求失败时为error
+*/
+func NewLogTransport(next http.RoundTripper, opts ...LogOption) http.RoundTripper {
+	registerClientMetrics()
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	options := logTransportOptions{
+		maxBodySize: defaultLogBodySize,
+		redactKeys:  defaultRedactKeys,
+		routeFunc:   RouteTemplate,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	transport := &logTransport{
+		next:    next,
+		options: options,
+	}
+	if len(options.redactKeys) > 0 {
+		var keys []string
+		for _, key := range options.redactKeys {
+			keys = append(keys, regexp.QuoteMeta(key))
+		}
+		keyPattern := "(?i)(" + strings.Join(keys, "|") + ")"
+		transport.redactJSON = regexp.MustCompile(`("` + keyPattern + `"\s*:\s*)"[^"]*"`)
+		transport.redactForm = regexp.MustCompile(`(\b` + keyPattern + `=)[^&\s]*`)
+	}
+	return transport
+}
+
+// WithOutgoingLog 开启出站请求日志和指标
+// 复制http.Client后替换Transport 不影响共用同一个http.Client的其他Client Transport已是日志Transport时不重复包装
+func (client *Client) WithOutgoingLog(opts ...LogOption) *Client {
+	if _, ok := client.c.Transport.(*logTransport); ok {
+		return client
+	}
+	httpClient := *client.c
+	httpClient.Transport = NewLogTransport(httpClient.Transport, opts...)
+	client.c = &httpClient
+	return client
+}
+
+func (transport *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	params := transport.requestBody(req)
+	response, err := transport.next.RoundTrip(req)
+	entry := &outgoingLog{
+		transport: transport,
+		req:       req,
+		params:    params,
+		latency:   time.Since(start),
+	}
+	if err != nil {
+		entry.err = err
+		entry.finish()
+		return response, err
+	}
+	entry.status = response.StatusCode
+	response.Body = &logBody{
+		ReadCloser: response.Body,
+		entry:      entry,
+		limit:      transport.options.maxBodySize,
+	}
+	return response, err
+}
+
+// requestBody 读取请求体副本 请求体不可重复读取时不记录
+func (transport *logTransport) requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	buf, _ := io.ReadAll(io.LimitReader(body, int64(transport.options.maxBodySize)))
+	return transport.redactString(string(buf))
+}
+
+func (transport *logTransport) redactString(s string) string {
+	if transport.redactJSON == nil {
+		return s
+	}
+	s = transport.redactJSON.ReplaceAllString(s, `${1}"***"`)
+	return transport.redactForm.ReplaceAllString(s, "${1}***")
+}
+
+func (transport *logTransport) redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = transport.redactString(u.RawQuery)
+	return redacted.String()
+}
+
+type outgoingLog struct {
+	transport *logTransport
+	req       *http.Request
+	params    string
+	latency   time.Duration
+	status    int
+	err       error
+	result    bytes.Buffer
+	once      sync.Once
+}
+
+func (entry *outgoingLog) finish() {
+	entry.once.Do(func() {
+		transport := entry.transport
+		req := entry.req
+		route := transport.options.routeFunc(req)
+		status := "error"
+		if entry.err == nil {
+			status = statusClass(entry.status)
+		}
+		clientRequestDuration.WithLabelValues(req.URL.Host, route, metricsMethod(req.Method), status).Observe(entry.latency.Seconds())
+		fields := logger.Fields{
+			"type":   "outgoing",
+			"ts":     float64(entry.latency.Nanoseconds()) / 1000000,
+			"url":    transport.redactURL(req.URL),
+			"host":   req.URL.Host,
+			"route":  route,
+			"Method": req.Method,
+			"status": entry.status,
+			"params": entry.params,
+			traceId:  req.Header.Get(traceId),
+		}
+		ctx := req.Context()
+		if entry.err != nil {
+			fields["error"] = entry.err.Error()
+			logger.WithFields(ctx, fields).Warnf("%s|%s", req.Method, status)
+			return
+		}
+		fields["result"] = transport.redactString(strings.Trim(entry.result.String(), "\n"))
+		logger.WithFields(ctx, fields).Infof("%s|%d", req.Method, entry.status)
+	})
+}
+
+// logBody 记录响应体前limit个字节 读取结束或关闭时输出日志
+type logBody struct {
+	io.ReadCloser
+	entry *outgoingLog
+	limit int
+}
+
+func (body *logBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if remain := body.limit - body.entry.result.Len(); remain > 0 && n > 0 {
+		if n < remain {
+			remain = n
+		}
+		body.entry.result.Write(p[:remain])
+	}
+	if err == io.EOF {
+		body.entry.finish()
+	}
+	return n, err
+}
+
+func (body *logBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.entry.finish()
+	return err
+}
+
+var routeIdSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{16,}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// RouteTemplate 将path中的数字 长hex uuid段替换为:id 避免指标基数过大
+func RouteTemplate(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		if routeIdSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,87 @@
+package httpz
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestClient_WithOutgoingLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"token":"abc","name":"tom"}`))
+	}))
+	defer server.Close()
+
+	hook := logtest.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	httpClient := server.Client()
+	transport := httpClient.Transport
+	client := NewClientWithHttpClient(httpClient).WithOutgoingLog().WithOutgoingLog()
+	if httpClient.Transport != transport {
+		t.Error("shared http.Client transport replaced")
+	}
+	if next := client.c.Transport.(*logTransport).next; next != transport {
+		t.Errorf("log transport wrapped twice %T", next)
+	}
+	resp, err := client.PostJson(context.Background(), server.URL+"/user/123/detail?access_token=xyz", map[string]string{"password": "123456"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != `{"token":"abc","name":"tom"}` {
+		t.Errorf("response body changed %s", body)
+	}
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Data["type"] == "outgoing" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("outgoing log not written")
+	}
+	if entry.Data["params"] != `{"password":"***"}` || entry.Data["result"] != `{"token":"***","name":"tom"}` {
+		t.Errorf("outgoing log not redacted params:%v result:%v", entry.Data["params"], entry.Data["result"])
+	}
+	if entry.Data["url"] != server.URL+"/user/123/detail?access_token=***" || entry.Data["route"] != "/user/:id/detail" || entry.Data["status"] != http.StatusOK {
+		t.Errorf("outgoing log fields %v", entry.Data)
+	}
+	if got := testutil.CollectAndCount(clientRequestDuration); got == 0 {
+		t.Error("client metrics not recorded")
+	}
+}
+
+func TestLogTransport_Redact(t *testing.T) {
+	transport := NewLogTransport(nil).(*logTransport)
+	if got := transport.redactString(`{"password":"123","name":"tom","Token" : "x"}`); got != `{"password":"***","name":"tom","Token" : "***"}` {
+		t.Errorf("json redact got %s", got)
+	}
+	if got := transport.redactString(`password=123&name=tom`); got != `password=***&name=tom` {
+		t.Errorf("form redact got %s", got)
+	}
+	u, _ := url.Parse("http://u:p@example.com/a?access_token=xyz&b=1")
+	if got := transport.redactURL(u); got != "http://example.com/a?access_token=***&b=1" {
+		t.Errorf("url redact got %s", got)
+	}
+}
+
+func TestRouteTemplate(t *testing.T) {
+	for path, want := range map[string]string{
+		"/user/123/detail": "/user/:id/detail",
+		"/story/6f1c2d3e-1a2b-4c5d-8e9f-0a1b2c3d4e5f": "/story/:id",
+		"/cgi-bin/media/upload":                       "/cgi-bin/media/upload",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		if got := RouteTemplate(req); got != want {
+			t.Errorf("RouteTemplate(%s) want %s got %s", path, want, got)
+		}
+	}
+}
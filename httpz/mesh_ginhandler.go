@@ -2,10 +2,10 @@ package httpz
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/trace"
 )
 
 var EnvKey = "env"
@@ -43,15 +43,19 @@ func MeshGinHandlerFunc() gin.HandlerFunc {
 		carrier.XB3Flags = ginCtx.Request.Header.Get(XB3FlagsKey)
 		carrier.XOtSpanContext = ginCtx.Request.Header.Get(XOtSpanContextKey)
 		ctx = context.WithValue(ctx, HTTPHeadersCarrierKey, carrier)
+		//未经过web.InitContext时 按trace.GetPropagator()读取链路信息
+		if ctx.Value(trace.TraceIdKey) == nil {
+			ctx, _ = trace.ContextWithHTTP(ctx, ginCtx.Request.Header)
+		}
 		ginCtx.Request = ginCtx.Request.WithContext(ctx)
 		ginCtx.Next()
 	}
 }
 
+// AddMeshHeader 将MeshGinHandlerFunc保存的mesh header写入header httpz.Client会自动调用
 func AddMeshHeader(ctx context.Context, header http.Header) {
 	value := ctx.Value(HTTPHeadersCarrierKey)
 	if value == nil {
-		fmt.Println("http_header_carrier not found")
 		return
 	}
 	if httpCarrier, ok := value.(*HTTPHeadersCarrier); ok {
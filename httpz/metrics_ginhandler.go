@@ -0,0 +1,90 @@
+package httpz
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute 未匹配到路由的请求统一归到该标签 避免按原始path打标签导致指标基数爆炸
+const unmatchedRoute = "unmatched"
+
+var (
+	metricsOnce sync.Once
+
+	serverRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests handled by gin.",
+	}, []string{"route", "method", "status"})
+
+	serverRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Latency of HTTP requests handled by gin.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	serverRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of HTTP requests currently being handled by gin.",
+	}, []string{"route", "method"})
+)
+
+func registerServerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(serverRequestsTotal, serverRequestDuration, serverRequestsInFlight)
+	})
+}
+
+/*
+*
+请求RED指标 注册到prometheus默认Registry 由DefaultApp的/metrics输出
+
+	route  路由模板 c.FullPath() 未匹配的路由为unmatched
+	method 请求方法 非标准方法为OTHER
+	status 状态码分类 例如2xx 5xx
+*/
+func MetricsGinHandlerFunc() gin.HandlerFunc {
+	registerServerMetrics()
+	return func(ginCtx *gin.Context) {
+		start := time.Now()
+		route := metricsRoute(ginCtx.FullPath())
+		method := metricsMethod(ginCtx.Request.Method)
+		inFlight := serverRequestsInFlight.WithLabelValues(route, method)
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			status := statusClass(ginCtx.Writer.Status())
+			serverRequestsTotal.WithLabelValues(route, method, status).Inc()
+			serverRequestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+		}()
+		ginCtx.Next()
+	}
+}
+
+func metricsRoute(fullPath string) string {
+	if fullPath == "" {
+		return unmatchedRoute
+	}
+	return fullPath
+}
+
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
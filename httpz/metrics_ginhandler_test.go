@@ -0,0 +1,40 @@
+package httpz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsGinHandlerFunc(t *testing.T) {
+	ginEngine := DefaultGin(nil)
+	ginEngine.GET("/metrics_test/:id", func(c *gin.Context) {
+		c.String(http.StatusCreated, "ok")
+	})
+
+	for _, path := range []string{"/metrics_test/1", "/metrics_test/2", "/metrics_test_unknown/3"} {
+		w := httptest.NewRecorder()
+		ginEngine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(serverRequestsTotal.WithLabelValues("/metrics_test/:id", "GET", "2xx")); got != 2 {
+		t.Errorf("route requests want 2 got %v", got)
+	}
+	if got := testutil.ToFloat64(serverRequestsTotal.WithLabelValues(unmatchedRoute, "GET", "4xx")); got != 1 {
+		t.Errorf("unmatched requests want 1 got %v", got)
+	}
+	if got := testutil.ToFloat64(serverRequestsInFlight.WithLabelValues("/metrics_test/:id", "GET")); got != 0 {
+		t.Errorf("in flight want 0 got %v", got)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, want := range map[int]string{200: "2xx", 302: "3xx", 404: "4xx", 503: "5xx", 0: "unknown"} {
+		if got := statusClass(status); got != want {
+			t.Errorf("statusClass(%d) want %s got %s", status, want, got)
+		}
+	}
+}
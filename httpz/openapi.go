@@ -0,0 +1,611 @@
+package httpz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	resultSchemaName    = "web.Result"
+	v2RequestSchemaName = "web.V2Request"
+)
+
+// DefaultOpenAPI 默认文档 服务模板中open_api路由注册到该文档
+var DefaultOpenAPI = NewOpenAPI("API", "1.0.0")
+
+// RouteSpec 接口描述
+type RouteSpec struct {
+	Summary     string
+	Description string
+	Tags        []string
+	//请求类型的零值 例如 CreateOrderReq{}
+	//字段名使用json(url参数使用form)tag binding tag中的required oneof生成必填和枚举 description tag生成字段说明
+	//GET DELETE 请求按form tag生成url参数 其他请求生成json请求体(同时支持V2请求格式)
+	Request interface{}
+	//响应content的类型的零值 响应为web.Result格式
+	Response interface{}
+	//接口可能返回的业务码 http状态码和提示从errorz.Register读取
+	Errors []int
+}
+
+type openAPIRoute struct {
+	method string
+	path   string
+	spec   RouteSpec
+}
+
+/*
+*
+OpenAPI 根据注册的路由生成OpenAPI 3文档
+示例:
+
+	router := httpz.DefaultOpenAPI.Router(openApiGroup)
+	router.POST("order/create", httpz.RouteSpec{
+		Summary:  "创建订单",
+		Request:  CreateOrderReq{},
+		Response: Order{},
+		Errors:   []int{CodeGoodsNotFound},
+	}, controller.CreateOrder)
+	ginEngine.GET("/inner/openapi.json", httpz.OpenAPIGinHandlerFunc(httpz.DefaultOpenAPI))
+*/
+type OpenAPI struct {
+	title   string
+	version string
+	mu      sync.RWMutex
+	routes  []openAPIRoute
+}
+
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{
+		title:   title,
+		version: version,
+	}
+}
+
+// SetInfo 设置文档标题和版本
+func (doc *OpenAPI) SetInfo(title, version string) *OpenAPI {
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+	doc.title = title
+	doc.version = version
+	return doc
+}
+
+// Router 包装gin路由组 注册路由时记录到文档
+func (doc *OpenAPI) Router(group *gin.RouterGroup) *Router {
+	return &Router{
+		group: group,
+		doc:   doc,
+	}
+}
+
+func (doc *OpenAPI) add(method, fullPath string, spec RouteSpec) {
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+	doc.routes = append(doc.routes, openAPIRoute{
+		method: method,
+		path:   fullPath,
+		spec:   spec,
+	})
+}
+
+// Router 记录接口描述的路由组
+type Router struct {
+	group *gin.RouterGroup
+	doc   *OpenAPI
+}
+
+// Group 创建子路由组
+func (router *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{
+		group: router.group.Group(relativePath, handlers...),
+		doc:   router.doc,
+	}
+}
+
+// Handle 注册路由并记录接口描述
+func (router *Router) Handle(method, relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	router.doc.add(method, joinRoutePath(router.group.BasePath(), relativePath), spec)
+	return router.group.Handle(method, relativePath, handlers...)
+}
+
+func (router *Router) GET(relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return router.Handle(http.MethodGet, relativePath, spec, handlers...)
+}
+
+func (router *Router) POST(relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return router.Handle(http.MethodPost, relativePath, spec, handlers...)
+}
+
+func (router *Router) PUT(relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return router.Handle(http.MethodPut, relativePath, spec, handlers...)
+}
+
+func (router *Router) PATCH(relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return router.Handle(http.MethodPatch, relativePath, spec, handlers...)
+}
+
+func (router *Router) DELETE(relativePath string, spec RouteSpec, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return router.Handle(http.MethodDelete, relativePath, spec, handlers...)
+}
+
+func joinRoutePath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+/*
+*
+OpenAPIGinHandlerFunc 输出文档
+路径以.yaml结尾或format=yaml时输出yaml 否则输出json
+*/
+func OpenAPIGinHandlerFunc(doc *OpenAPI) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		if strings.HasSuffix(ginCtx.Request.URL.Path, ".yaml") || ginCtx.Query("format") == "yaml" {
+			body, err := doc.YAML()
+			if err != nil {
+				ginCtx.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			ginCtx.Data(http.StatusOK, "application/yaml; charset=utf-8", body)
+			return
+		}
+		body, err := doc.JSON()
+		if err != nil {
+			ginCtx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		ginCtx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// JSON 生成json格式文档
+func (doc *OpenAPI) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc.Document(), "", "  ")
+}
+
+// YAML 生成yaml格式文档
+func (doc *OpenAPI) YAML() ([]byte, error) {
+	body, err := json.Marshal(doc.Document())
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err = json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(document)
+}
+
+// Document 生成文档
+func (doc *OpenAPI) Document() *OpenAPIDocument {
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+	builder := newSchemaBuilder()
+	document := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   doc.title,
+			Version: doc.version,
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+	}
+	for _, route := range doc.routes {
+		docPath, pathParams := openAPIPath(route.path)
+		if document.Paths[docPath] == nil {
+			document.Paths[docPath] = map[string]*openAPIOperation{}
+		}
+		document.Paths[docPath][strings.ToLower(route.method)] = builder.operation(route, pathParams)
+	}
+	builder.schemas[resultSchemaName] = &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"code":     {Type: "integer", Format: "int64", Description: "业务码 0为成功"},
+			"msg":      {Type: "string"},
+			"content":  {Description: "业务响应", Nullable: true},
+			"alert":    {Type: "string", Description: "用户提示"},
+			"trace_id": {Type: "string"},
+		},
+		Required: []string{"code", "msg", "content", "alert", "trace_id"},
+	}
+	builder.schemas[v2RequestSchemaName] = &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"version": {Type: "string", Enum: []interface{}{"V2"}},
+			"params":  {Description: "请求参数"},
+		},
+		Required: []string{"version", "params"},
+	}
+	document.Components.Schemas = builder.schemas
+	return document
+}
+
+// OpenAPIDocument OpenAPI 3文档
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationId string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	ErrorCodes  []openAPIErrorCode          `json:"x-error-codes,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIErrorCode struct {
+	Code       int    `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+	Alert      string `json:"alert,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	OneOf                []*openAPISchema          `json:"oneOf,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+}
+
+var routeParamPattern = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIPath gin路径转为OpenAPI路径 例如 /order/:id -> /order/{id}
+func openAPIPath(ginPath string) (string, []string) {
+	var params []string
+	docPath := routeParamPattern.ReplaceAllStringFunc(ginPath, func(s string) string {
+		params = append(params, s[1:])
+		return "{" + s[1:] + "}"
+	})
+	if !strings.HasPrefix(docPath, "/") {
+		docPath = "/" + docPath
+	}
+	return docPath, params
+}
+
+var operationIdPattern = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: map[string]*openAPISchema{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+func (builder *schemaBuilder) operation(route openAPIRoute, pathParams []string) *openAPIOperation {
+	spec := route.spec
+	operation := &openAPIOperation{
+		OperationId: strings.ToLower(route.method) + strings.Trim(operationIdPattern.ReplaceAllString(route.path, "_"), "_"),
+		Summary:     spec.Summary,
+		Description: spec.Description,
+		Tags:        spec.Tags,
+		Responses:   map[string]*openAPIResponse{},
+	}
+	for _, param := range pathParams {
+		operation.Parameters = append(operation.Parameters, &openAPIParameter{
+			Name:     param,
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		})
+	}
+	if spec.Request != nil {
+		requestType := reflect.TypeOf(spec.Request)
+		if route.method == http.MethodGet || route.method == http.MethodDelete {
+			operation.Parameters = append(operation.Parameters, builder.queryParameters(requestType)...)
+		} else {
+			request := builder.schemaOf(requestType)
+			v2 := &openAPISchema{AllOf: []*openAPISchema{
+				{Ref: schemaRef(v2RequestSchemaName)},
+				{Type: "object", Properties: map[string]*openAPISchema{"params": request}},
+			}}
+			operation.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: &openAPISchema{OneOf: []*openAPISchema{request, v2}}},
+				},
+			}
+		}
+	}
+
+	content := &openAPISchema{Nullable: true}
+	if spec.Response != nil {
+		content = builder.schemaOf(reflect.TypeOf(spec.Response))
+	}
+	operation.Responses["200"] = &openAPIResponse{
+		Description: "成功 code为0",
+		Content:     resultContent(content),
+	}
+	//按http状态码分组错误码
+	codesByStatus := map[int][]string{}
+	for _, code := range spec.Errors {
+		status, alert, ok := errorz.Registered(code)
+		if !ok {
+			status = http.StatusOK
+		}
+		operation.ErrorCodes = append(operation.ErrorCodes, openAPIErrorCode{
+			Code:       code,
+			HTTPStatus: status,
+			Alert:      alert,
+		})
+		codesByStatus[status] = append(codesByStatus[status], strings.TrimSpace(strconv.Itoa(code)+" "+alert))
+	}
+	for status, codes := range codesByStatus {
+		description := "错误码: " + strings.Join(codes, ", ")
+		if status == http.StatusOK {
+			operation.Responses["200"].Description += "; " + description
+			continue
+		}
+		operation.Responses[strconv.Itoa(status)] = &openAPIResponse{
+			Description: description,
+			Content:     resultContent(&openAPISchema{Nullable: true}),
+		}
+	}
+	return operation
+}
+
+func resultContent(content *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{
+		"application/json": {Schema: &openAPISchema{AllOf: []*openAPISchema{
+			{Ref: schemaRef(resultSchemaName)},
+			{Type: "object", Properties: map[string]*openAPISchema{"content": content}},
+		}}},
+	}
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// queryParameters 结构体字段转为url参数 参数名使用form tag
+func (builder *schemaBuilder) queryParameters(t reflect.Type) []*openAPIParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var parameters []*openAPIParameter
+	for _, field := range structFields(t, "form") {
+		parameters = append(parameters, &openAPIParameter{
+			Name:     field.name,
+			In:       "query",
+			Required: field.required,
+			Schema:   builder.fieldSchema(field),
+		})
+	}
+	return parameters
+}
+
+type schemaField struct {
+	name     string
+	field    reflect.StructField
+	required bool
+}
+
+// structFields 导出字段 匿名结构体字段展开
+func structFields(t reflect.Type, tagName string) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
+		if name == "" && tagName != "json" {
+			name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			fields = append(fields, structFields(fieldType, tagName)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, schemaField{
+			name:     name,
+			field:    field,
+			required: hasRule(field, "required"),
+		})
+	}
+	return fields
+}
+
+// hasRule binding或validate tag中是否有该规则
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, tag := range []string{"binding", "validate"} {
+		for _, item := range strings.Split(field.Tag.Get(tag), ",") {
+			if item == rule || strings.HasPrefix(item, rule+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ruleParam(field reflect.StructField, rule string) string {
+	for _, tag := range []string{"binding", "validate"} {
+		for _, item := range strings.Split(field.Tag.Get(tag), ",") {
+			if strings.HasPrefix(item, rule+"=") {
+				return strings.TrimPrefix(item, rule+"=")
+			}
+		}
+	}
+	return ""
+}
+
+func (builder *schemaBuilder) fieldSchema(field schemaField) *openAPISchema {
+	schema := builder.schemaOf(field.field.Type)
+	_, options, _ := strings.Cut(field.field.Tag.Get("json"), ",")
+	if strings.Contains(options, "string") {
+		schema = &openAPISchema{Type: "string"}
+	}
+	if oneof := ruleParam(field.field, "oneof"); oneof != "" && schema.Ref == "" {
+		schema = &openAPISchema{Type: schema.Type, Format: schema.Format}
+		for _, value := range strings.Fields(oneof) {
+			schema.Enum = append(schema.Enum, value)
+		}
+	}
+	if description := field.field.Tag.Get("description"); description != "" {
+		if schema.Ref != "" {
+			schema = &openAPISchema{AllOf: []*openAPISchema{schema}}
+		} else {
+			copied := *schema
+			schema = &copied
+		}
+		schema.Description = description
+	}
+	return schema
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (builder *schemaBuilder) schemaOf(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &openAPISchema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: builder.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: builder.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return builder.structSchema(t)
+		}
+		return &openAPISchema{Ref: schemaRef(builder.structName(t))}
+	}
+	return &openAPISchema{}
+}
+
+var schemaNamePattern = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// structName 具名结构体注册到components 名称为 包名.类型名
+func (builder *schemaBuilder) structName(t reflect.Type) string {
+	if name, ok := builder.names[t]; ok {
+		return name
+	}
+	base := schemaNamePattern.ReplaceAllString(path.Base(t.PkgPath())+"."+t.Name(), "_")
+	name := base
+	for i := 2; builder.schemas[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	builder.names[t] = name
+	//先占位 避免递归类型死循环
+	builder.schemas[name] = &openAPISchema{}
+	*builder.schemas[name] = *builder.structSchema(t)
+	return name
+}
+
+func (builder *schemaBuilder) structSchema(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{
+		Type:       "object",
+		Properties: map[string]*openAPISchema{},
+	}
+	for _, field := range structFields(t, "json") {
+		schema.Properties[field.name] = builder.fieldSchema(field)
+		if field.required {
+			schema.Required = append(schema.Required, field.name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
@@ -0,0 +1,112 @@
+package httpz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+)
+
+const codeGoodsNotFound = 30404
+
+type createOrderReq struct {
+	GoodsId int64  `json:"goods_id" binding:"required,gt=0" description:"商品id"`
+	PayType string `json:"pay_type" binding:"oneof=wechat alipay"`
+	Items   []orderItem
+}
+
+type orderItem struct {
+	Sku      string     `json:"sku"`
+	Children *orderItem `json:"children,omitempty"`
+}
+
+type order struct {
+	OrderId  int64     `json:"order_id,string"`
+	CreateAt time.Time `json:"create_at"`
+}
+
+type listOrderReq struct {
+	Page int    `form:"page" binding:"required"`
+	Kw   string `form:"kw"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	errorz.Register(codeGoodsNotFound, http.StatusNotFound, "商品不存在")
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	doc := NewOpenAPI("test", "1.0.0")
+	router := doc.Router(engine.Group("open_api/"))
+	handler := func(ginCtx *gin.Context) {}
+	router.POST("order/create", RouteSpec{
+		Summary:  "创建订单",
+		Request:  createOrderReq{},
+		Response: order{},
+		Errors:   []int{codeGoodsNotFound, 30001},
+	}, handler)
+	router.Group("order").GET(":order_id/list", RouteSpec{Request: listOrderReq{}, Response: []order{}}, handler)
+	engine.GET("/inner/openapi.json", OpenAPIGinHandlerFunc(doc))
+	engine.GET("/inner/openapi.yaml", OpenAPIGinHandlerFunc(doc))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/inner/openapi.json", nil))
+	var document struct {
+		Paths      map[string]map[string]openAPIOperation `json:"paths"`
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	create, ok := document.Paths["/open_api/order/create"]["post"]
+	if !ok {
+		t.Fatalf("paths = %v", document.Paths)
+	}
+	if len(create.RequestBody.Content["application/json"].Schema.OneOf) != 2 {
+		t.Errorf("request body should accept plain and V2 envelope")
+	}
+	if _, ok = create.Responses["404"]; !ok || !strings.Contains(create.Responses["200"].Description, "30001") {
+		t.Errorf("responses = %+v", create.Responses)
+	}
+	if len(create.ErrorCodes) != 2 || create.ErrorCodes[0].Alert != "商品不存在" {
+		t.Errorf("error codes = %+v", create.ErrorCodes)
+	}
+
+	req := document.Components.Schemas["httpz.createOrderReq"]
+	if req == nil || len(req.Required) != 1 || req.Required[0] != "goods_id" {
+		t.Fatalf("createOrderReq = %+v", req)
+	}
+	if len(req.Properties["pay_type"].Enum) != 2 || req.Properties["goods_id"].Description != "商品id" {
+		t.Errorf("properties = %+v", req.Properties)
+	}
+	if req.Properties["Items"].Items.Ref != "#/components/schemas/httpz.orderItem" {
+		t.Errorf("items = %+v", req.Properties["Items"])
+	}
+	if document.Components.Schemas["httpz.orderItem"].Properties["children"].Ref != "#/components/schemas/httpz.orderItem" {
+		t.Errorf("recursive type not referenced")
+	}
+	orderSchema := document.Components.Schemas["httpz.order"]
+	if orderSchema.Properties["order_id"].Type != "string" || orderSchema.Properties["create_at"].Format != "date-time" {
+		t.Errorf("order = %+v", orderSchema.Properties)
+	}
+	if document.Components.Schemas[resultSchemaName] == nil || document.Components.Schemas[v2RequestSchemaName] == nil {
+		t.Errorf("envelope schemas missing")
+	}
+
+	list := document.Paths["/open_api/order/{order_id}/list"]["get"]
+	if len(list.Parameters) != 3 || list.Parameters[0].In != "path" || list.Parameters[1].Name != "page" || !list.Parameters[1].Required {
+		t.Errorf("parameters = %+v", list.Parameters)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/inner/openapi.yaml", nil))
+	if !strings.Contains(w.Body.String(), "openapi: 3.0.3") {
+		t.Errorf("yaml = %s", w.Body.String())
+	}
+}
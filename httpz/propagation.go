@@ -0,0 +1,130 @@
+package httpz
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/trace"
+	"github.com/songlma/gobase/web"
+)
+
+type propagation struct {
+	disabled bool
+	external bool            //外部host也传递全部header
+	allow    map[string]bool //为nil时传递全部header
+}
+
+/*
+*
+InternalHostSuffixes 内部服务的host后缀 只有内部host默认传递链路header(CorralId Trace-ID x-request-id 等)
+不带.的主机名 localhost 回环和内网IP同样视为内部host
+外部host需要通过WithPropagation或WithPropagateHeaders显式开启
+*/
+var InternalHostSuffixes = []string{".svc", ".svc.cluster.local", ".internal", ".local"}
+
+// WithPropagateHeaders 只传递指定的header 例如对外部接口只传递Trace-ID 外部host同样传递
+// 默认传递 mesh header(env x-request-id x-b3-*) opentracing span context trace.GetPropagator()的格式 CorralId
+func (client *Client) WithPropagateHeaders(keys ...string) *Client {
+	allow := map[string]bool{}
+	for _, key := range keys {
+		allow[http.CanonicalHeaderKey(key)] = true
+	}
+	client.propagation = &propagation{allow: allow}
+	return client
+}
+
+// WithPropagation 外部host也传递全部链路header 默认只对InternalHostSuffixes中的内部host传递
+func (client *Client) WithPropagation() *Client {
+	client.propagation = &propagation{external: true}
+	return client
+}
+
+// WithoutPropagation 不传递任何链路header
+func (client *Client) WithoutPropagation() *Client {
+	client.propagation = &propagation{disabled: true}
+	return client
+}
+
+// allowHost 未设置WithPropagation或WithPropagateHeaders时 只对内部host传递
+func (propagation *propagation) allowHost(host string) bool {
+	if propagation == nil {
+		return isInternalHost(host)
+	}
+	if propagation.disabled {
+		return false
+	}
+	return propagation.external || propagation.allow != nil || isInternalHost(host)
+}
+
+func isInternalHost(host string) bool {
+	if host == "" || host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate()
+	}
+	for _, suffix := range InternalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// propagate 将ctx中的链路信息写入请求header span不为nil时注入span context
+func (client *Client) propagate(ctx context.Context, req *http.Request, span opentracing.Span) {
+	if !client.propagation.allowHost(req.URL.Hostname()) {
+		return
+	}
+	header := http.Header{}
+	AddMeshHeader(ctx, header)
+	//mesh header中的B3需要原样传递 tracer和trace.GetPropagator()写入的B3不能覆盖或混用
+	meshB3 := header.Get(XB3TraceIdKey) != ""
+	if span == nil {
+		span = opentracing.SpanFromContext(ctx)
+	}
+	if span != nil {
+		injected := http.Header{}
+		_ = span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(injected))
+		mergeTraceHeader(header, injected, meshB3)
+	}
+	//tracer和mesh header之外 按trace.GetPropagator()写入W3C B3 Trace-ID等
+	tc := trace.TraceContextFromContext(ctx)
+	if !tc.Valid() {
+		tc.TraceId, _ = contextz.GetTraceID(ctx)
+	}
+	propagated := http.Header{}
+	trace.GetPropagator().Inject(tc, propagated)
+	mergeTraceHeader(header, propagated, meshB3)
+	if corralId, err := contextz.GetCorralID(ctx); err == nil && corralId != "" {
+		header.Set(web.CorralIdKey, corralId)
+	}
+	for key, values := range header {
+		if client.propagation != nil && client.propagation.allow != nil && !client.propagation.allow[key] {
+			continue
+		}
+		//调用方显式设置的header优先
+		if req.Header.Get(key) != "" {
+			continue
+		}
+		req.Header[key] = values
+	}
+}
+
+// mergeTraceHeader 将from中header中没有的key写入header meshB3为true时跳过B3
+func mergeTraceHeader(header, from http.Header, meshB3 bool) {
+	if meshB3 {
+		for _, key := range []string{trace.B3TraceIdHeader, trace.B3SpanIdHeader, trace.B3ParentSpanHeader, trace.B3SampledHeader, trace.B3FlagsHeader} {
+			from.Del(key)
+		}
+	}
+	for key, values := range from {
+		if _, ok := header[key]; !ok {
+			header[key] = values
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package httpz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/trace/tracetest"
+	"github.com/songlma/gobase/web"
+)
+
+func TestClient_Propagate(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	ctx = context.WithValue(ctx, HTTPHeadersCarrierKey, &HTTPHeadersCarrier{Env: "gray", XRequestId: "req-1"})
+	ctx, _ = contextz.SetCorralID(ctx, "corral")
+	ctx, _ = contextz.SetTraceID(ctx, "trace")
+
+	client := NewClientWithHttpClient(server.Client())
+	resp, err := client.Get(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if header.Get(EnvKey) != "gray" || header.Get(XRequestIdKey) != "req-1" || header.Get(web.CorralIdKey) != "corral" || header.Get(TraceIdKey) != "trace" {
+		t.Errorf("headers not propagated %v", header)
+	}
+	if header.Get("Mockpfx-Ids-Traceid") == "" {
+		t.Errorf("span context not injected %v", header)
+	}
+
+	client = NewClientWithHttpClient(server.Client()).WithPropagateHeaders(TraceIdKey)
+	resp, err = client.Get(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if header.Get(TraceIdKey) != "trace" || header.Get(EnvKey) != "" || header.Get("Mockpfx-Ids-Traceid") != "" {
+		t.Errorf("allow list not applied %v", header)
+	}
+
+	client = NewClientWithHttpClient(server.Client()).WithoutPropagation()
+	resp, err = client.Get(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if header.Get(TraceIdKey) != "" {
+		t.Errorf("propagation not disabled %v", header)
+	}
+}
+
+type headerRoundTripper struct {
+	header http.Header
+}
+
+func (transport *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport.header = req.Header.Clone()
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestClient_PropagateExternalHost(t *testing.T) {
+	ctx := context.WithValue(context.Background(), HTTPHeadersCarrierKey, &HTTPHeadersCarrier{XRequestId: "req-1"})
+	ctx, _ = contextz.SetCorralID(ctx, "corral")
+	ctx, _ = contextz.SetTraceID(ctx, "trace")
+	cases := []struct {
+		url      string
+		client   func(*Client) *Client
+		traceId  string
+		internal bool
+	}{
+		{url: "https://api.example.com/v1", client: func(c *Client) *Client { return c }},
+		{url: "http://user.svc.cluster.local/v1", client: func(c *Client) *Client { return c }, traceId: "trace", internal: true},
+		{url: "http://user-server:8080/v1", client: func(c *Client) *Client { return c }, traceId: "trace", internal: true},
+		{url: "http://10.0.0.1/v1", client: func(c *Client) *Client { return c }, traceId: "trace", internal: true},
+		{url: "https://api.example.com/v1", client: func(c *Client) *Client { return c.WithPropagation() }, traceId: "trace", internal: true},
+		{url: "https://api.example.com/v1", client: func(c *Client) *Client { return c.WithPropagateHeaders(TraceIdKey) }, traceId: "trace"},
+	}
+	for _, c := range cases {
+		transport := &headerRoundTripper{}
+		client := c.client(NewClientWithHttpClient(&http.Client{Transport: transport}))
+		resp, err := client.Get(ctx, c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		header := transport.header
+		if header.Get(TraceIdKey) != c.traceId {
+			t.Errorf("%s Trace-ID = %q", c.url, header.Get(TraceIdKey))
+		}
+		if internal := header.Get(web.CorralIdKey) != "" && header.Get(XRequestIdKey) != ""; internal != c.internal {
+			t.Errorf("%s internal headers %v", c.url, header)
+		}
+	}
+}
+
+// b3Injector 写入B3 header 模拟B3格式的tracer
+type b3Injector struct{}
+
+func (b3Injector) Inject(ctx mocktracer.MockSpanContext, carrier interface{}) error {
+	header := carrier.(opentracing.HTTPHeadersCarrier)
+	header.Set("x-b3-traceid", "tracer-trace")
+	header.Set("x-b3-spanid", "tracer-span")
+	header.Set("uber-trace-id", "tracer-trace:tracer-span:0:1")
+	return nil
+}
+
+func TestClient_PropagateMeshB3(t *testing.T) {
+	tracer := mocktracer.New()
+	tracer.RegisterInjector(opentracing.HTTPHeaders, b3Injector{})
+	span := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	ctx = context.WithValue(ctx, HTTPHeadersCarrierKey, &HTTPHeadersCarrier{XB3TraceId: "mesh-trace", XB3SpanId: "mesh-span"})
+	transport := &headerRoundTripper{}
+	resp, err := NewClientWithHttpClient(&http.Client{Transport: transport}).Get(ctx, "http://user-server/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	header := transport.header
+	if header.Get("X-B3-TraceId") != "mesh-trace" || header.Get("X-B3-SpanId") != "mesh-span" {
+		t.Errorf("mesh b3 overwritten %v", header)
+	}
+	if header.Get("uber-trace-id") == "" {
+		t.Errorf("tracer header not injected %v", header)
+	}
+}
+
+func TestMeshGinHandlerFunc_Propagator(t *testing.T) {
+	var header http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	client := NewClientWithHttpClient(downstream.Client())
+	engine := gin.New()
+	engine.Use(MeshGinHandlerFunc())
+	engine.GET("/proxy", func(ginCtx *gin.Context) {
+		resp, err := client.Get(ginCtx.Request.Context(), downstream.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = resp.Body.Close()
+	})
+	req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if header.Get(TraceIdKey) != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Trace-ID = %s", header.Get(TraceIdKey))
+	}
+	traceparent := header.Get("traceparent")
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Errorf("traceparent = %s", traceparent)
+	}
+	if header.Get("X-B3-TraceId") != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("X-B3-TraceId = %s", header.Get("X-B3-TraceId"))
+	}
+}
+
+func TestClient_Opentracing(t *testing.T) {
+	recorder := tracetest.Install(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	parent, ctx := opentracing.StartSpanFromContext(context.Background(), "parent")
+	client := NewClientWithHttpClient(server.Client())
+	client.Opentracing = true
+	resp, err := client.Get(ctx, server.URL+"/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	parent.Finish()
+
+	recorder.AssertSpan(t, "HTTP Client GET *").
+		ChildOf(recorder.AssertSpan(t, "parent")).
+		HasTag("span.kind", "client").
+		HasTag("http.method", http.MethodGet).
+		HasTag("http.url", server.URL+"/ping").
+		NoError()
+}
@@ -0,0 +1,269 @@
+package httpz
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/logger"
+	"github.com/songlma/gobase/redisz"
+)
+
+// CodeTooManyRequests 限流时web.Result中的code
+const CodeTooManyRequests int64 = http.StatusTooManyRequests
+
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+)
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64         //窗口内允许的请求数
+	Remaining int64         //窗口内剩余请求数
+	Reset     time.Duration //配额完全恢复的时间
+	//被拒绝时 距离下次可请求的时间
+	RetryAfter time.Duration
+}
+
+// RateLimiter 限流器
+type RateLimiter interface {
+	// Allow 消耗key的一次配额
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc 限流key 返回空字符串时不限流
+type RateLimitKeyFunc func(ginCtx *gin.Context) string
+
+// RateLimitByIP 按客户端ip限流 ip为gin ClientIP 只信任DefaultGin配置的可信代理(默认集群内网段)转发的X-Forwarded-For
+// 未使用DefaultGin时需要自行调用 gin.Engine.SetTrustedProxies 否则客户端可以伪造X-Forwarded-For绕过限流
+func RateLimitByIP(ginCtx *gin.Context) string {
+	return "ip:" + ginCtx.ClientIP()
+}
+
+// RateLimitByUID 按contextz中的uid限流 未登录时按ip限流
+func RateLimitByUID(ginCtx *gin.Context) string {
+	if uid, err := contextz.GetUID(ginCtx.Request.Context()); err == nil && uid != "" {
+		return "uid:" + uid
+	}
+	return RateLimitByIP(ginCtx)
+}
+
+/*
+*
+RateLimitGinHandlerFunc 限流中间件
+响应header
+RateLimit-Limit RateLimit-Remaining RateLimit-Reset(秒)
+被限流时返回429 并设置Retry-After(秒) 响应体为web.Result
+限流器出错时不限流 只记录日志
+示例:
+
+	limiter := httpz.NewRedisSlidingWindowLimiter(redisPool, "rate_limit:open_api:", 100, time.Minute)
+	openApiGroup := ginEngine.Group("open_api/", httpz.RateLimitGinHandlerFunc(limiter, httpz.RateLimitByIP))
+*/
+func RateLimitGinHandlerFunc(limiter RateLimiter, key RateLimitKeyFunc) gin.HandlerFunc {
+	if key == nil {
+		key = RateLimitByIP
+	}
+	return func(ginCtx *gin.Context) {
+		limitKey := key(ginCtx)
+		if limitKey == "" {
+			ginCtx.Next()
+			return
+		}
+		ctx := ginCtx.Request.Context()
+		result, err := limiter.Allow(ctx, limitKey)
+		if err != nil {
+			logger.WithFields(ctx, logger.Fields{"type": "rate_limit"}).Errorf("rate limit key:%s err:%v", limitKey, err)
+			ginCtx.Next()
+			return
+		}
+		header := ginCtx.Writer.Header()
+		header.Set(rateLimitLimitHeader, strconv.FormatInt(result.Limit, 10))
+		header.Set(rateLimitRemainingHeader, strconv.FormatInt(result.Remaining, 10))
+		header.Set(rateLimitResetHeader, strconv.FormatInt(ceilSeconds(result.Reset), 10))
+		if !result.Allowed {
+			header.Set(retryAfterHeader, strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+			abortResult(ginCtx, http.StatusTooManyRequests, "too many requests", "请求过于频繁，请稍后再试")
+			return
+		}
+		ginCtx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// 本地令牌桶清理空闲桶的间隔
+const bucketSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type tokenBucketLimiter struct {
+	rate      float64 //每秒补充的令牌数
+	burst     float64
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+/*
+*
+NewTokenBucketLimiter 进程内令牌桶 多实例部署时每个实例单独计数
+rate  每秒补充的令牌数
+burst 桶容量 即允许的突发请求数
+*/
+func NewTokenBucketLimiter(rate float64, burst int) RateLimiter {
+	return &tokenBucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+func (limiter *tokenBucketLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	now := limiter.now()
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.sweep(now)
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: limiter.burst, last: now}
+		limiter.buckets[key] = bucket
+	}
+	limiter.refill(bucket, now)
+	result := RateLimitResult{Limit: int64(limiter.burst)}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = limiter.duration(1 - bucket.tokens)
+	}
+	result.Remaining = int64(bucket.tokens)
+	result.Reset = limiter.duration(limiter.burst - bucket.tokens)
+	return result, nil
+}
+
+func (limiter *tokenBucketLimiter) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(limiter.burst, bucket.tokens+elapsed.Seconds()*limiter.rate)
+		bucket.last = now
+	}
+}
+
+// duration 补充tokens个令牌需要的时间
+func (limiter *tokenBucketLimiter) duration(tokens float64) time.Duration {
+	if limiter.rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / limiter.rate * float64(time.Second))
+}
+
+// sweep 删除已经补满的桶 避免key过多时内存增长
+func (limiter *tokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < bucketSweepInterval {
+		return
+	}
+	limiter.lastSweep = now
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+		if bucket.tokens >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// KEYS[1] 限流key
+// ARGV[1] 当前时间(毫秒) ARGV[2] 窗口(毫秒) ARGV[3] 窗口内允许的请求数 ARGV[4] 本次请求的member
+// 返回 {是否允许, 剩余请求数, 窗口内最早请求过期的毫秒数}
+var slidingWindowScript = redisz.NewScript(1, `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+type redisSlidingWindowLimiter struct {
+	pool   *redisz.Pool
+	prefix string
+	limit  int64
+	window time.Duration
+	seq    uint64
+}
+
+/*
+*
+NewRedisSlidingWindowLimiter redis滑动窗口 多实例共享计数
+prefix redis key前缀 例如 rate_limit:open_api:
+limit  window时间内允许的请求数
+*/
+func NewRedisSlidingWindowLimiter(pool *redisz.Pool, prefix string, limit int64, window time.Duration) RateLimiter {
+	return &redisSlidingWindowLimiter{
+		pool:   pool,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (limiter *redisSlidingWindowLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	conn := limiter.pool.GetConn()
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	now := time.Now().UnixMilli()
+	//同一毫秒内的多个请求(包括其他实例)需要不同的member
+	member := fmt.Sprintf("%d-%d-%d", now, atomic.AddUint64(&limiter.seq, 1), rand.Int63())
+	reply, err := redisz.Int64s(conn.Eval(ctx, slidingWindowScript, limiter.prefix+key, now, limiter.window.Milliseconds(), limiter.limit, member))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(reply) != 3 {
+		return RateLimitResult{}, fmt.Errorf("rate limit script reply %v", reply)
+	}
+	result := RateLimitResult{
+		Allowed:   reply[0] == 1,
+		Limit:     limiter.limit,
+		Remaining: reply[1],
+		Reset:     time.Duration(reply[2]) * time.Millisecond,
+	}
+	if !result.Allowed {
+		result.RetryAfter = result.Reset
+	}
+	return result, nil
+}
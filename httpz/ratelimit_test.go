@@ -0,0 +1,139 @@
+package httpz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/redisz"
+	"github.com/songlma/gobase/web"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewTokenBucketLimiter(1, 2).(*tokenBucketLimiter)
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, _ := limiter.Allow(ctx, "a")
+		if !result.Allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	result, _ := limiter.Allow(ctx, "a")
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != time.Second {
+		t.Fatalf("result = %+v", result)
+	}
+	//其他key不受影响
+	if result, _ = limiter.Allow(ctx, "b"); !result.Allowed {
+		t.Fatal("key b rejected")
+	}
+	now = now.Add(1500 * time.Millisecond)
+	if result, _ = limiter.Allow(ctx, "a"); !result.Allowed {
+		t.Fatalf("refilled request rejected %+v", result)
+	}
+
+	//空闲的桶被清理
+	now = now.Add(2 * bucketSweepInterval)
+	_, _ = limiter.Allow(ctx, "c")
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("buckets = %d, want 1", len(limiter.buckets))
+	}
+}
+
+type errLimiter struct{}
+
+func (errLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("redis down")
+}
+
+func TestRateLimitGinHandlerFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/limited", RateLimitGinHandlerFunc(NewTokenBucketLimiter(0.5, 1), RateLimitByUID), func(ginCtx *gin.Context) {
+		ginCtx.String(http.StatusOK, "ok")
+	})
+	engine.GET("/fail_open", RateLimitGinHandlerFunc(errLimiter{}, nil), func(ginCtx *gin.Context) {
+		ginCtx.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "2" {
+		t.Fatalf("status = %d header = %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status = %d header = %v", w.Code, w.Header())
+	}
+	var result web.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != CodeTooManyRequests || result.Alert == "" {
+		t.Fatalf("result = %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail_open", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("fail open status = %d", w.Code)
+	}
+}
+
+func TestRedisSlidingWindowLimiter(t *testing.T) {
+	ctx := context.Background()
+	pool := redisz.NewPool(ctx, "localhost:6379", "")
+	defer pool.Close(ctx)
+	conn := pool.GetConn()
+	if err := conn.Ping(ctx); err != nil {
+		_ = conn.Close(ctx)
+		t.Skip("redis unavailable:", err)
+	}
+	_ = conn.Close(ctx)
+	limiter := NewRedisSlidingWindowLimiter(pool, "test_rate_limit:"+time.Now().String()+":", 2, time.Second)
+	for i := 0; i < 2; i++ {
+		result, err := limiter.Allow(ctx, "a")
+		if err != nil || !result.Allowed {
+			t.Fatalf("request %d result = %+v err = %v", i, result, err)
+		}
+	}
+	result, err := limiter.Allow(ctx, "a")
+	if err != nil || result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("result = %+v err = %v", result, err)
+	}
+}
+
+func TestRateLimitByIP_TrustedProxies(t *testing.T) {
+	engine := DefaultGin(DefaultConfig().DisableMetrics())
+	engine.GET("/ip", func(ginCtx *gin.Context) {
+		ginCtx.String(http.StatusOK, RateLimitByIP(ginCtx))
+	})
+	key := func(remoteAddr, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	//集群内代理转发 取右侧第一个非可信地址 左侧伪造的地址不影响
+	if got := key("10.0.0.2:1234", "9.9.9.9, 1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Errorf("via ingress got %s", got)
+	}
+	if got := key("10.0.0.2:1234", "8.8.8.8, 1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Errorf("via ingress spoofed got %s", got)
+	}
+	//直连的客户端不读取X-Forwarded-For
+	if got := key("192.0.2.1:1234", "9.9.9.9"); got != "ip:192.0.2.1" {
+		t.Errorf("direct client got %s", got)
+	}
+}
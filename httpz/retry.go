@@ -0,0 +1,310 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/breakerz"
+)
+
+const (
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+)
+
+// RetryPolicy 重试策略
+// 默认只重试幂等方法(GET HEAD OPTIONS PUT DELETE TRACE)或带有Idempotency-Key的请求
+// 连接错误以及Statuses中的状态码会触发重试 等待时间为指数退避加随机抖动 响应带Retry-After时以Retry-After为准
+type RetryPolicy struct {
+	MaxAttempts        int           //总尝试次数 包含首次请求
+	BaseDelay          time.Duration //首次重试的最大等待时间 默认100ms
+	MaxDelay           time.Duration //最大等待时间 默认2s Retry-After超过该值时不再重试
+	Statuses           []int         //需要重试的状态码 默认502 503 504
+	RetryNonIdempotent bool          //非幂等请求也重试
+}
+
+// HedgePolicy 对冲请求策略 仅对幂等请求生效
+// 请求超过Delay未返回时 再发起一个相同请求 最多额外发起MaxHedges个 使用最先返回的成功响应
+type HedgePolicy struct {
+	Delay     time.Duration
+	MaxHedges int
+}
+
+// DefaultRetryPolicy 最多3次 退避100ms起 最大2s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+		Statuses:    []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// WithRetry 设置重试策略
+func (client *Client) WithRetry(policy RetryPolicy) *Client {
+	client.retry = &policy
+	return client
+}
+
+// WithHedge 设置对冲请求策略
+func (client *Client) WithHedge(policy HedgePolicy) *Client {
+	client.hedge = &policy
+	return client
+}
+
+func (policy *RetryPolicy) maxAttempts(req *http.Request) int {
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return 1
+	}
+	if !policy.RetryNonIdempotent && !isIdempotent(req) {
+		return 1
+	}
+	return policy.MaxAttempts
+}
+
+func (policy *RetryPolicy) maxDelay() time.Duration {
+	if policy.MaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+	return policy.MaxDelay
+}
+
+// backoff 第attempt次请求失败后的等待时间 full jitter
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	base := policy.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	delay := policy.maxDelay()
+	if shift := attempt - 1; shift < 32 && base<<shift < delay {
+		delay = base << shift
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func (policy *RetryPolicy) retryableStatus(status int) bool {
+	var statuses []int
+	if policy != nil {
+		statuses = policy.Statuses
+	}
+	if len(statuses) == 0 {
+		statuses = DefaultRetryPolicy().Statuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// IdempotencyKeyHeader 带有该header的请求视为幂等请求
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// retryAfter 解析Retry-After 支持秒数和HTTP-date
+func retryAfter(response *http.Response) time.Duration {
+	if response == nil {
+		return 0
+	}
+	value := response.Header.Get(retryAfterHeader)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+func discardResponse(response *http.Response) {
+	if response == nil || response.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4<<10))
+	_ = response.Body.Close()
+}
+
+// doRetry 按重试策略发送请求
+func (client *Client) doRetry(ctx context.Context, req *http.Request, span opentracing.Span) (*http.Response, error) {
+	maxAttempts := client.retry.maxAttempts(req)
+	hedge := client.hedge != nil && client.hedge.MaxHedges > 0 && isIdempotent(req)
+	if maxAttempts > 1 || hedge {
+		//保证请求体可重复读取
+		if _, err := requestBody(req); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		var response *http.Response
+		var err error
+		if hedge {
+			response, err = client.hedgeSend(ctx, req, attempt, span)
+		} else {
+			response, err = client.send(ctx, req, attempt, 0, span)
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil || errors.Is(err, breakerz.OpenErr) {
+			return response, err
+		}
+		if err == nil && !client.retry.retryableStatus(response.StatusCode) {
+			return response, err
+		}
+		wait := client.retry.backoff(attempt)
+		if after := retryAfter(response); after > 0 {
+			if after > client.retry.maxDelay() {
+				return response, err
+			}
+			wait = after
+		}
+		discardResponse(response)
+		if span != nil {
+			span.LogKV("event", "retry", "attempt", attempt+1, "wait", wait.String())
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send 发送一次请求 每次请求使用独立的请求体和签名
+func (client *Client) send(ctx context.Context, req *http.Request, attempt, hedge int, span opentracing.Span) (*http.Response, error) {
+	attemptReq := req.Clone(ctx)
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+	if client.signer != nil {
+		if err := client.signer.sign(attemptReq); err != nil {
+			return nil, err
+		}
+	}
+	var done func(outcome breakerz.Outcome)
+	if client.breaker != nil {
+		var err error
+		if done, err = client.breaker.group.Get(client.breaker.option.Key(attemptReq)).Allow(); err != nil {
+			if span != nil {
+				span.LogKV("event", "attempt", "attempt", attempt, "error", err.Error())
+			}
+			return nil, err
+		}
+	}
+	start := time.Now()
+	response, err := client.c.Do(attemptReq)
+	if done != nil {
+		done(client.breaker.outcome(ctx, response, err))
+	}
+	if span != nil {
+		fields := []interface{}{"event", "attempt", "attempt", attempt, "ts", float64(time.Since(start).Nanoseconds()) / 1000000}
+		if hedge > 0 {
+			fields = append(fields, "hedge", hedge)
+		}
+		if err != nil {
+			fields = append(fields, "error", err.Error())
+		} else {
+			fields = append(fields, "status", response.StatusCode)
+		}
+		span.LogKV(fields...)
+	}
+	return response, err
+}
+
+type hedgeResult struct {
+	response *http.Response
+	err      error
+	hedge    int
+}
+
+// cancelBody 关闭响应体时取消对应请求的context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// hedgeSend 对冲请求 返回最先成功的响应 其余请求会被取消
+func (client *Client) hedgeSend(ctx context.Context, req *http.Request, attempt int, span opentracing.Span) (*http.Response, error) {
+	results := make(chan hedgeResult, client.hedge.MaxHedges+1)
+	cancels := make([]context.CancelFunc, client.hedge.MaxHedges+1)
+	launch := func(hedge int) {
+		hedgeCtx, cancel := context.WithCancel(ctx)
+		cancels[hedge] = cancel
+		go func() {
+			response, err := client.send(hedgeCtx, req, attempt, hedge, span)
+			results <- hedgeResult{response: response, err: err, hedge: hedge}
+		}()
+	}
+	launch(0)
+	launched, inflight := 1, 1
+	timer := time.NewTimer(client.hedge.Delay)
+	defer timer.Stop()
+	var last *hedgeResult
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if launched <= client.hedge.MaxHedges {
+				launch(launched)
+				launched++
+				inflight++
+				timer.Reset(client.hedge.Delay)
+			}
+		case result := <-results:
+			inflight--
+			if result.err == nil && !client.retry.retryableStatus(result.response.StatusCode) {
+				//取消其余请求 并释放其响应
+				for hedge := 0; hedge < launched; hedge++ {
+					if hedge != result.hedge {
+						cancels[hedge]()
+					}
+				}
+				go func(inflight int) {
+					for i := 0; i < inflight; i++ {
+						discardResponse((<-results).response)
+					}
+				}(inflight)
+				result.response.Body = &cancelBody{ReadCloser: result.response.Body, cancel: cancels[result.hedge]}
+				return result.response, nil
+			}
+			if last != nil {
+				discardResponse(last.response)
+				cancels[last.hedge]()
+			}
+			last = &result
+		}
+	}
+	if last.response != nil {
+		last.response.Body = &cancelBody{ReadCloser: last.response.Body, cancel: cancels[last.hedge]}
+	} else {
+		cancels[last.hedge]()
+	}
+	return last.response, last.err
+}
@@ -0,0 +1,108 @@
+package httpz
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.RetryNonIdempotent = true
+	client := NewClientWithHttpClient(server.Client()).WithRetry(policy)
+	resp, err := client.PostJson(ctx, server.URL, map[string]int{"id": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"id":1}` || calls != 3 {
+		t.Errorf("want 200 after 3 calls got %d %s calls:%d", resp.StatusCode, body, calls)
+	}
+
+	//POST默认不重试
+	atomic.StoreInt32(&calls, 0)
+	client = NewClientWithHttpClient(server.Client()).WithRetry(DefaultRetryPolicy())
+	resp, err = client.PostJson(ctx, server.URL, map[string]int{"id": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != 1 {
+		t.Errorf("want 503 after 1 call got %d calls:%d", resp.StatusCode, calls)
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClientWithHttpClient(server.Client()).WithRetry(DefaultRetryPolicy())
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	//Retry-After 超过 MaxDelay 不再重试
+	if calls != 1 {
+		t.Errorf("want 1 call got %d", calls)
+	}
+}
+
+func TestClient_Hedge(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("hedge"))
+	}))
+	defer server.Close()
+
+	client := NewClientWithHttpClient(server.Client()).WithHedge(HedgePolicy{Delay: 20 * time.Millisecond, MaxHedges: 1})
+	start := time.Now()
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "hedge" || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("want hedged response got %s after %v", body, time.Since(start))
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt < 40; attempt++ {
+		if wait := policy.backoff(attempt); wait < 0 || wait > time.Second {
+			t.Errorf("attempt %d backoff %v out of range", attempt, wait)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songlma/gobase/config"
+	"github.com/songlma/gobase/redisz"
+)
+
+const nonce = "nonce"
+
+const defaultSignSkew = 5 * time.Minute
+
+// NonceStore 防重放 nonce 存储
+// Claim 在ttl内首次出现时返回true 重复出现返回false
+type NonceStore interface {
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// SignConfig 内部请求签名配置
+type SignConfig struct {
+	Secrets  map[string]string //调用方Service-Name对应的签名密钥
+	Skew     time.Duration     //允许的时间偏差 默认5分钟
+	NonceTTL time.Duration     //nonce保存时间 默认2倍Skew
+	Nonce    NonceStore        //nonce存储 为nil时不做重放校验
+}
+
+// SignNoncePrefix LoadSignConfig使用的nonce redis key前缀
+const SignNoncePrefix = "inner_sign_nonce:"
+
+type signFileConfig struct {
+	Secrets map[string]string `mapstructure:"secrets"`
+	Skew    int64             `mapstructure:"skew"`
+}
+
+/*
+*
+从配置加载签名配置 例如key为secret.inner_sign
+
+	secret:
+	  inner_sign:
+	    skew: 300 #秒
+	    secrets:
+	      poster: xxxxxx
+
+redisPool 不为nil时 使用redis做nonce防重放
+配置解析失败时返回error secrets为空时拒绝所有调用方
+*/
+func LoadSignConfig(key string, redisPool *redisz.Pool) (*SignConfig, error) {
+	var fileConfig signFileConfig
+	if err := config.UnmarshalKey(key, &fileConfig); err != nil {
+		return nil, fmt.Errorf("httpz: load sign config %s: %w", key, err)
+	}
+	signConfig := &SignConfig{
+		Secrets: fileConfig.Secrets,
+		Skew:    time.Duration(fileConfig.Skew) * time.Second,
+	}
+	if redisPool != nil {
+		signConfig.Nonce = NewRedisNonceStore(redisPool, SignNoncePrefix)
+	}
+	return signConfig, nil
+}
+
+func (signConfig *SignConfig) skew() time.Duration {
+	if signConfig.Skew <= 0 {
+		return defaultSignSkew
+	}
+	return signConfig.Skew
+}
+
+func (signConfig *SignConfig) nonceTTL() time.Duration {
+	if signConfig.NonceTTL <= 0 {
+		return 2 * signConfig.skew()
+	}
+	return signConfig.NonceTTL
+}
+
+type redisNonceStore struct {
+	pool   *redisz.Pool
+	prefix string
+}
+
+// NewRedisNonceStore 基于redis SET NX EX 的nonce存储
+func NewRedisNonceStore(pool *redisz.Pool, prefix string) NonceStore {
+	return &redisNonceStore{
+		pool:   pool,
+		prefix: prefix,
+	}
+}
+
+func (store *redisNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	conn := store.pool.GetConn()
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	seconds := int64(ttl / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	ok, err := conn.SetNxEx(ctx, store.prefix+key, 1, seconds)
+	if errors.Is(err, redisz.ErrNil) {
+		return false, nil
+	}
+	return ok, err
+}
+
+/*
+*
+签名 HMAC-SHA256 hex编码
+签名内容按行拼接:
+
+	method
+	path http请求为SignPath的结果 包含排序后的query
+	timestamp
+	nonce
+	hex(sha256(body))
+	serviceName
+*/
+func Sign(secret, method, path, timestamp, nonce, serviceName string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	content := strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+		serviceName,
+	}, "\n")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+*
+SignPath 签名使用的path 有query时为 path?query
+query按key排序并统一编码 参数顺序和编码方式不影响签名 修改参数值会使签名失效
+*/
+func SignPath(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	query := u.RawQuery
+	if values, err := url.ParseQuery(u.RawQuery); err == nil {
+		query = values.Encode()
+	}
+	return u.Path + "?" + query
+}
+
+type requestSigner struct {
+	serviceName string
+	secret      string
+}
+
+// sign 设置签名相关header 会读取并重置req.Body
+func (signer *requestSigner) sign(req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	nonceBytes := make([]byte, 16)
+	if _, err = rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	timestampValue := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceValue := hex.EncodeToString(nonceBytes)
+	req.Header.Set(serviceName, signer.serviceName)
+	req.Header.Set(timestamp, timestampValue)
+	req.Header.Set(nonce, nonceValue)
+	req.Header.Set(sign, Sign(signer.secret, req.Method, SignPath(req.URL), timestampValue, nonceValue, signer.serviceName, body))
+	return nil
+}
+
+// requestBody 读取请求体 并保证请求体可以再次读取
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		return io.ReadAll(reader)
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
@@ -0,0 +1,179 @@
+package httpz
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]bool
+}
+
+func (store *memoryNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.nonces[key] {
+		return false, nil
+	}
+	store.nonces[key] = true
+	return true, nil
+}
+
+func newSignTestServer() *httptest.Server {
+	ginEngine := gin.New()
+	innerGroup := ginEngine.Group("inner/", InterSignGinHandlerFunc(&SignConfig{
+		Secrets: map[string]string{"poster": "poster-secret"},
+		Nonce:   &memoryNonceStore{nonces: map[string]bool{}},
+	}))
+	innerGroup.POST("/echo", func(c *gin.Context) {
+		body, _ := GetInnerRequestParams(c)
+		c.String(http.StatusOK, string(body))
+	})
+	innerGroup.GET("/user", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Query("uid"))
+	})
+	return httptest.NewServer(ginEngine)
+}
+
+func TestInterSignGinHandlerFunc(t *testing.T) {
+	server := newSignTestServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	client := NewClientWithHttpClient(server.Client()).WithSign("poster", "poster-secret")
+	resp, err := client.PostJson(ctx, server.URL+"/inner/echo", map[string]string{"id": "1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"id":"1"}` {
+		t.Errorf("signed request want 200 got %d %s", resp.StatusCode, body)
+	}
+
+	wrongSecret := NewClientWithHttpClient(server.Client()).WithSign("poster", "other-secret")
+	resp, err = wrongSecret.PostJson(ctx, server.URL+"/inner/echo", map[string]string{"id": "1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wrong secret want 400 got %d", resp.StatusCode)
+	}
+}
+
+func TestInterSignGinHandlerFunc_Replay(t *testing.T) {
+	server := newSignTestServer()
+	defer server.Close()
+
+	body := `{"id":"1"}`
+	timestampValue := strconv.FormatInt(time.Now().Unix(), 10)
+	newRequest := func(timestampValue string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/inner/echo", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(serviceName, "poster")
+		req.Header.Set(timestamp, timestampValue)
+		req.Header.Set(nonce, "n1")
+		req.Header.Set(sign, Sign("poster-secret", http.MethodPost, "/inner/echo", timestampValue, "n1", "poster", []byte(body)))
+		return req
+	}
+	for i, want := range []int{http.StatusOK, http.StatusBadRequest} {
+		resp, err := server.Client().Do(newRequest(timestampValue))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("request %d want %d got %d", i, want, resp.StatusCode)
+		}
+	}
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	resp, err := server.Client().Do(newRequest(expired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expired timestamp want 400 got %d", resp.StatusCode)
+	}
+}
+
+func TestInterSignGinHandlerFunc_Query(t *testing.T) {
+	server := newSignTestServer()
+	defer server.Close()
+
+	//按 ?uid=1&from=app 签名 发送不同的query
+	signed, _ := url.Parse("/inner/user?uid=1&from=app")
+	timestampValue := strconv.FormatInt(time.Now().Unix(), 10)
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		//参数顺序不影响签名
+		{name: "reordered", query: "from=app&uid=1", want: http.StatusOK},
+		{name: "tampered", query: "uid=2&from=app", want: http.StatusBadRequest},
+		{name: "dropped", query: "", want: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonceValue := "q" + strconv.Itoa(i)
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/inner/user?"+tt.query, nil)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(serviceName, "poster")
+			req.Header.Set(timestamp, timestampValue)
+			req.Header.Set(nonce, nonceValue)
+			req.Header.Set(sign, Sign("poster-secret", http.MethodGet, SignPath(signed), timestampValue, nonceValue, "poster", nil))
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("want %d got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestLoadSignConfig(t *testing.T) {
+	viper.Set("secret.inner_sign_test", map[string]interface{}{
+		"skew":    60,
+		"secrets": map[string]string{"poster": "poster-secret"},
+	})
+	signConfig, err := LoadSignConfig("secret.inner_sign_test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signConfig.Secrets["poster"] != "poster-secret" || signConfig.skew() != time.Minute || signConfig.Nonce != nil {
+		t.Errorf("sign config got %+v", signConfig)
+	}
+
+	viper.Set("secret.inner_sign_bad", "not a map")
+	signConfig, err = LoadSignConfig("secret.inner_sign_bad", nil)
+	if err == nil || signConfig != nil {
+		t.Errorf("bad config want error got %v %v", signConfig, err)
+	}
+}
+
+func TestInterSignGinHandlerFunc_NilConfig(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("nil sign config want panic")
+		}
+	}()
+	InterSignGinHandlerFunc(nil)
+}
@@ -0,0 +1,206 @@
+package httpz
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSEvent Server-Sent Events 事件
+type SSEvent struct {
+	Id    string
+	Event string //事件类型 为空时客户端按message处理
+	//string和[]byte原样发送 其他类型以json发送 多行数据按行拆分为多个data字段
+	Data  interface{}
+	Retry time.Duration //客户端重连间隔
+}
+
+/*
+*
+StreamWriter 流式响应 每次写入后立即Flush
+创建时取消http.Server的WriteTimeout 客户端断开后写入返回ctx.Err()
+*/
+type StreamWriter struct {
+	ginCtx *gin.Context
+	mu     sync.Mutex
+}
+
+/*
+*
+NewStreamWriter 设置Content-Type并发送响应头
+例如 application/x-ndjson text/plain
+*/
+func NewStreamWriter(ginCtx *gin.Context, contentType string) *StreamWriter {
+	header := ginCtx.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	//关闭nginx缓冲
+	header.Set("X-Accel-Buffering", "no")
+	//流式响应可能超过Server的WriteTimeout
+	_ = http.NewResponseController(ginCtx.Writer).SetWriteDeadline(time.Time{})
+	ginCtx.Status(http.StatusOK)
+	ginCtx.Writer.WriteHeaderNow()
+	ginCtx.Writer.Flush()
+	return &StreamWriter{ginCtx: ginCtx}
+}
+
+// Write 写入并Flush
+func (w *StreamWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.ginCtx.Request.Context().Err(); err != nil {
+		return 0, err
+	}
+	n, err := w.ginCtx.Writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+	w.ginCtx.Writer.Flush()
+	return n, nil
+}
+
+// WriteJSON 以一行json写入 用于application/x-ndjson
+func (w *StreamWriter) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// SSEWriter Server-Sent Events
+type SSEWriter struct {
+	*StreamWriter
+}
+
+// NewSSEWriter 设置Content-Type为text/event-stream并发送响应头
+func NewSSEWriter(ginCtx *gin.Context) *SSEWriter {
+	return &SSEWriter{StreamWriter: NewStreamWriter(ginCtx, "text/event-stream; charset=utf-8")}
+}
+
+// Send 发送事件
+func (w *SSEWriter) Send(event SSEvent) error {
+	var buf bytes.Buffer
+	if event.Id != "" {
+		buf.WriteString("id: " + sseLine(event.Id) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + sseLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data string
+	switch value := event.Data.(type) {
+	case nil:
+	case string:
+		data = value
+	case []byte:
+		data = string(value)
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Comment 发送注释 客户端会忽略 用于心跳
+func (w *SSEWriter) Comment(text string) error {
+	_, err := w.Write([]byte(": " + sseLine(text) + "\n\n"))
+	return err
+}
+
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+/*
+*
+StreamSSE 发送events中的事件 直到events关闭或客户端断开
+heartbeat>0时 空闲heartbeat后发送心跳注释 避免代理断开连接
+示例:
+
+	func Progress(ginCtx *gin.Context) {
+		events := make(chan httpz.SSEvent)
+		go task.Run(ginCtx.Request.Context(), events) //完成后close(events)
+		if err := httpz.StreamSSE(ginCtx, 15*time.Second, events); err != nil {
+			logger.Warn(ginCtx.Request.Context(), "StreamSSE:", err)
+		}
+	}
+
+err
+
+	客户端断开时返回ctx.Err()
+*/
+func StreamSSE(ginCtx *gin.Context, heartbeat time.Duration, events <-chan SSEvent) error {
+	w := NewSSEWriter(ginCtx)
+	return streamLoop(ginCtx, heartbeat, events, w.Send, func() error {
+		return w.Comment("ping")
+	})
+}
+
+/*
+*
+Stream 发送chunks中的数据 直到chunks关闭或客户端断开
+heartbeat>0且heartbeatData不为空时 空闲heartbeat后发送heartbeatData 例如ndjson的"\n"
+*/
+func Stream(ginCtx *gin.Context, contentType string, heartbeat time.Duration, heartbeatData []byte, chunks <-chan []byte) error {
+	w := NewStreamWriter(ginCtx, contentType)
+	if len(heartbeatData) == 0 {
+		heartbeat = 0
+	}
+	return streamLoop(ginCtx, heartbeat, chunks, func(chunk []byte) error {
+		_, err := w.Write(chunk)
+		return err
+	}, func() error {
+		_, err := w.Write(heartbeatData)
+		return err
+	})
+}
+
+func streamLoop[T any](ginCtx *gin.Context, heartbeat time.Duration, items <-chan T, send func(T) error, ping func() error) error {
+	ctx := ginCtx.Request.Context()
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok := <-items:
+			if !ok {
+				return nil
+			}
+			if err := send(item); err != nil {
+				return err
+			}
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		case <-tick:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
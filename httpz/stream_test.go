@@ -0,0 +1,86 @@
+package httpz
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	logWriter := GetBodyLogWriter()
+	result := make(chan error, 1)
+	engine.GET("/events", func(ginCtx *gin.Context) {
+		logWriter.Init(ginCtx.Writer)
+		ginCtx.Writer = logWriter
+		events := make(chan SSEvent)
+		go func() {
+			events <- SSEvent{Id: "1", Event: "progress", Data: map[string]int{"percent": 50}}
+			events <- SSEvent{Data: "line1\nline2"}
+		}()
+		result <- StreamSSE(ginCtx, 20*time.Millisecond, events)
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("content type = %s", resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 9 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimRight(line, "\n"))
+	}
+	want := []string{"id: 1", "event: progress", `data: {"percent":50}`, "", "data: line1", "data: line2", "", ": ping", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("lines = %q", lines)
+	}
+	cancel()
+	select {
+	case err = <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamSSE did not stop after client disconnect")
+	}
+	if !logWriter.Streaming() || logWriter.BodyString() != "" {
+		t.Fatalf("streaming = %v body = %q", logWriter.Streaming(), logWriter.BodyString())
+	}
+}
+
+func TestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/stream", func(ginCtx *gin.Context) {
+		chunks := make(chan []byte, 2)
+		chunks <- []byte(`{"a":1}` + "\n")
+		chunks <- []byte(`{"a":2}` + "\n")
+		close(chunks)
+		_ = Stream(ginCtx, "application/x-ndjson", time.Second, []byte("\n"), chunks)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Body.String() != "{\"a\":1}\n{\"a\":2}\n" || !w.Flushed {
+		t.Fatalf("body = %q flushed = %v", w.Body.String(), w.Flushed)
+	}
+}
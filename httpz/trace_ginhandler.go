@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/songlma/gobase/trace"
 )
 
 const defaultComponentName = "net/http"
@@ -98,7 +99,13 @@ func OpenTracingGinHandlerFunc(tr opentracing.Tracer, options ...MWOption) gin.H
 			return
 		}
 		carrier := opentracing.HTTPHeadersCarrier(c.Request.Header)
-		ctx, _ := tr.Extract(opentracing.HTTPHeaders, carrier)
+		ctx, err := tr.Extract(opentracing.HTTPHeaders, carrier)
+		//tracer格式中没有时 使用trace.GetPropagator()支持的W3C B3格式
+		if err != nil {
+			if tc, ok := trace.ExtractHTTP(c.Request.Header); ok {
+				ctx, _ = trace.ExtractSpanContext(tr, tc)
+			}
+		}
 		op := opts.opNameFunc(c.Request)
 		span := tr.StartSpan(op, ext.RPCServerOption(ctx))
 		ext.HTTPMethod.Set(span, c.Request.Method)
@@ -14,3 +14,11 @@ func GetRequestServiceName(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithRequestServiceName 保存调用方服务名 与grpc metadata中sign_service_name的格式一致
+func WithRequestServiceName(ctx context.Context, serviceName string) context.Context {
+	if serviceName == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, grpcSignServiceName, []string{serviceName})
+}
@@ -35,6 +35,7 @@ type options struct {
 	file      string                           //日志保存地址
 	errorFile string                           //错误日志保存地址
 	traceFun  func(ctx context.Context) string //返回traceId的方法
+	sampling  map[string]SamplingConfig        //按级别配置的日志采样
 }
 
 func initOptions(opts ...func(*options)) options {
@@ -54,6 +55,7 @@ var Opt struct {
 	File      func(file string) func(*options)
 	ErrorFile func(file string) func(*options)
 	TraceFun  func(traceFun func(ctx context.Context) string) func(*options)
+	Sampling  func(level string, config SamplingConfig) func(*options)
 }
 
 // InitLog
@@ -61,6 +63,7 @@ var Opt struct {
 // traceFun 获取traceId方法  默认使用 trace.TraceIDFromContext
 // Opt.Fmt(FmtText) 设置格式化类型 默认FMT_TEXT类型
 // Opt.Level(LevelDebug) 设置日志级别 默认LevelDebug级别
+// Opt.Sampling(LevelError, DefaultSamplingConfig()) 设置指定级别的日志采样 默认不采样
 func InitLog(ctx context.Context, project string, traceF func(ctx context.Context) string, opts ...func(*options)) func() {
 	option := initOptions(opts...)
 	svc = project
@@ -113,8 +116,26 @@ func InitLog(ctx context.Context, project string, traceF func(ctx context.Contex
 		errorLogger.SetOutput(io.MultiWriter(os.Stderr))
 	}
 
+	logSampler.Swap(nil).close()
+	var current *sampler
+	if len(option.sampling) > 0 {
+		configs := map[logrus.Level]SamplingConfig{}
+		for level, config := range option.sampling {
+			samplingLevel, err := logrus.ParseLevel(level)
+			if err != nil {
+				log.Fatal("logger:illegal sampling level ", level)
+			}
+			configs[samplingLevel] = config
+		}
+		current = newSampler(configs)
+		current.start()
+		logSampler.Store(current)
+	}
+
 	return func() {
 		Debug(ctx, "logger:defer close logger")
+		logSampler.CompareAndSwap(current, nil)
+		current.close()
 		if file != nil {
 			_ = file.Close()
 		}
@@ -150,4 +171,12 @@ func init() {
 			o.traceFun = traceFun
 		}
 	}
+	Opt.Sampling = func(level string, config SamplingConfig) func(*options) {
+		return func(o *options) {
+			if o.sampling == nil {
+				o.sampling = map[string]SamplingConfig{}
+			}
+			o.sampling[level] = config
+		}
+	}
 }
@@ -41,16 +41,25 @@ func init() {
 }
 
 func Debug(ctx context.Context, args ...interface{}) {
+	if !sampleAllow(logrus.DebugLevel, "", args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Debug(args...)
 }
 
 func Debugf(ctx context.Context, format string, args ...interface{}) {
+	if !sampleAllow(logrus.DebugLevel, format, args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Debugf(format, args...)
 }
 
 func Error(ctx context.Context, args ...interface{}) {
+	if !sampleAllow(logrus.ErrorLevel, "", args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	fields := map[string]interface{}{}
 	var errArgs []interface{}
@@ -72,6 +81,9 @@ func Error(ctx context.Context, args ...interface{}) {
 }
 
 func Errorf(ctx context.Context, format string, args ...interface{}) {
+	if !sampleAllow(logrus.ErrorLevel, format, args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	fields := map[string]interface{}{}
 	//errorLogger.Errorf(format, args...)
@@ -90,31 +102,49 @@ func Errorf(ctx context.Context, format string, args ...interface{}) {
 }
 
 func Info(ctx context.Context, args ...interface{}) {
+	if !sampleAllow(logrus.InfoLevel, "", args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Info(args...)
 }
 
 func Infof(ctx context.Context, format string, args ...interface{}) {
+	if !sampleAllow(logrus.InfoLevel, format, args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Infof(format, args...)
 }
 
 func Trace(ctx context.Context, args ...interface{}) {
+	if !sampleAllow(logrus.TraceLevel, "", args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Trace(args...)
 }
 
 func Tracef(ctx context.Context, format string, args ...interface{}) {
+	if !sampleAllow(logrus.TraceLevel, format, args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Tracef(format, args...)
 }
 
 func Warn(ctx context.Context, args ...interface{}) {
+	if !sampleAllow(logrus.WarnLevel, "", args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Warn(args...)
 }
 
 func Warnf(ctx context.Context, format string, args ...interface{}) {
+	if !sampleAllow(logrus.WarnLevel, format, args) {
+		return
+	}
 	entry := logrus.WithContext(ctx)
 	commonEntry(ctx, entry, nil).Warnf(format, args...)
 }
@@ -131,7 +161,7 @@ func commonEntry(ctx context.Context, entry *logrus.Entry, fields logrus.Fields)
 		traceId = traceFun(ctx)
 	} else if ctx != nil {
 		value, err := contextz.GetTraceID(ctx)
-		if err != nil {
+		if err == nil {
 			traceId = value
 		}
 	}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/sirupsen/logrus"
+	"github.com/songlma/gobase/contextz"
 	"github.com/songlma/gobase/errorz"
 )
 
@@ -109,3 +111,18 @@ func TestErrorf(t *testing.T) {
 	errz := errorz.FromStd(sql.ErrNoRows)
 	Errorf(ctx, "error log:%s", errz)
 }
+
+func TestCommonEntry_ContextTraceID(t *testing.T) {
+	old := traceFun
+	traceFun = nil
+	defer func() { traceFun = old }()
+	ctx, _ := contextz.SetTraceID(context.Background(), "trace-1")
+	entry := commonEntry(ctx, logrus.WithContext(ctx), nil)
+	if entry.Data["trace"] != "trace-1" {
+		t.Errorf("trace want trace-1 got %v", entry.Data["trace"])
+	}
+	entry = commonEntry(context.Background(), logrus.WithContext(context.Background()), nil)
+	if entry.Data["trace"] != "" {
+		t.Errorf("trace want empty got %v", entry.Data["trace"])
+	}
+}
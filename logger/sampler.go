@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SamplingConfig 日志采样配置
+// 同一级别下 按 消息模板+caller 去重
+// 每个Tick周期内 前First条全部输出 之后每Thereafter条输出1条(Thereafter<=0 时全部丢弃)
+// 被丢弃的条数会在周期结束后以 "suppressed K similar messages" 汇总输出
+type SamplingConfig struct {
+	Tick       time.Duration
+	First      int
+	Thereafter int
+}
+
+// DefaultSamplingConfig 每秒前100条 之后每100条输出1条
+func DefaultSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Tick:       time.Second,
+		First:      100,
+		Thereafter: 100,
+	}
+}
+
+// logSampler InitLog时替换 日志调用方并发读取
+var logSampler atomic.Pointer[sampler]
+
+type sampleKey struct {
+	level    logrus.Level
+	caller   string
+	template string
+}
+
+type sampleCounter struct {
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+type sampler struct {
+	mu       sync.Mutex
+	configs  map[logrus.Level]SamplingConfig
+	counters map[sampleKey]*sampleCounter
+	now      func() time.Time
+	report   func(key sampleKey, suppressed int)
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSampler(configs map[logrus.Level]SamplingConfig) *sampler {
+	s := &sampler{
+		configs:  map[logrus.Level]SamplingConfig{},
+		counters: map[sampleKey]*sampleCounter{},
+		now:      time.Now,
+		report:   reportSuppressed,
+	}
+	for level, config := range configs {
+		if config.Tick <= 0 {
+			config.Tick = time.Second
+		}
+		s.configs[level] = config
+	}
+	return s
+}
+
+// allow 判断当前日志是否输出
+func (s *sampler) allow(level logrus.Level, caller, template string) bool {
+	if s == nil {
+		return true
+	}
+	config, ok := s.configs[level]
+	if !ok {
+		return true
+	}
+	key := sampleKey{level: level, caller: caller, template: template}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, ok := s.counters[key]
+	if !ok {
+		counter = &sampleCounter{windowStart: now}
+		s.counters[key] = counter
+	} else if now.Sub(counter.windowStart) >= config.Tick {
+		counter.windowStart = now
+		counter.count = 0
+	}
+	counter.count++
+	if counter.count <= config.First {
+		return true
+	}
+	if config.Thereafter > 0 && (counter.count-config.First)%config.Thereafter == 0 {
+		return true
+	}
+	counter.suppressed++
+	return false
+}
+
+// flush 输出被丢弃日志的汇总 并清理过期的计数
+func (s *sampler) flush() {
+	now := s.now()
+	type suppressedItem struct {
+		key        sampleKey
+		suppressed int
+	}
+	var items []suppressedItem
+	s.mu.Lock()
+	for key, counter := range s.counters {
+		if counter.suppressed > 0 {
+			items = append(items, suppressedItem{key: key, suppressed: counter.suppressed})
+			counter.suppressed = 0
+		}
+		if now.Sub(counter.windowStart) >= s.configs[key.level].Tick {
+			delete(s.counters, key)
+		}
+	}
+	s.mu.Unlock()
+	for _, item := range items {
+		s.report(item.key, item.suppressed)
+	}
+}
+
+func (s *sampler) start() {
+	var tick time.Duration
+	for _, config := range s.configs {
+		if tick == 0 || config.Tick < tick {
+			tick = config.Tick
+		}
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flush()
+			case <-s.stop:
+				s.flush()
+				return
+			}
+		}
+	}()
+}
+
+func (s *sampler) close() {
+	if s == nil || s.stop == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func reportSuppressed(key sampleKey, suppressed int) {
+	logrus.WithFields(logrus.Fields{
+		"svc":        svc,
+		"caller":     key.caller,
+		"type":       "sampling",
+		"suppressed": suppressed,
+	}).Logf(key.level, "suppressed %d similar messages: %s", suppressed, key.template)
+}
+
+// sampleAllow 日志采样判断 未开启采样或级别未开启采样时 始终返回true
+// format 为空时 使用args生成模板 caller按调用点pc缓存 不在每条日志上解析调用栈
+func sampleAllow(level logrus.Level, format string, args []interface{}) bool {
+	s := logSampler.Load()
+	if s == nil {
+		return true
+	}
+	if _, ok := s.configs[level]; !ok || !logrus.IsLevelEnabled(level) {
+		return true
+	}
+	template := format
+	if template == "" {
+		template = argsTemplate(args)
+	}
+	return s.allow(level, sampleCaller(), template)
+}
+
+// sampleCallers 调用点pc对应的caller 调用点数量有限 不做淘汰
+var sampleCallers sync.Map
+
+// sampleCaller 0:runtime.Callers 1:sampleCaller 2:sampleAllow 3:Errorf等 4:调用方
+func sampleCaller() string {
+	var pcs [1]uintptr
+	if runtime.Callers(4, pcs[:]) == 0 {
+		return ""
+	}
+	if caller, ok := sampleCallers.Load(pcs[0]); ok {
+		return caller.(string)
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	caller := fmt.Sprintf("%v:%d", frame.Function, frame.Line)
+	sampleCallers.Store(pcs[0], caller)
+	return caller
+}
+
+// argsTemplate 非格式化日志的模板 取第一个字符串参数 其余参数按类型表示
+func argsTemplate(args []interface{}) string {
+	var parts []string
+	for i, arg := range args {
+		if s, ok := arg.(string); ok && i == 0 {
+			parts = append(parts, s)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%T", arg))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSampler_Allow(t *testing.T) {
+	now := time.Now()
+	s := newSampler(map[logrus.Level]SamplingConfig{
+		logrus.ErrorLevel: {Tick: time.Second, First: 3, Thereafter: 5},
+	})
+	s.now = func() time.Time { return now }
+	suppressed := map[string]int{}
+	s.report = func(key sampleKey, n int) {
+		suppressed[key.template] += n
+	}
+
+	allowed := 0
+	for i := 0; i < 23; i++ {
+		if s.allow(logrus.ErrorLevel, "redisz.errorLog:20", "err:%v") {
+			allowed++
+		}
+	}
+	// 前3条 + 第8、13、18、23条
+	if allowed != 7 {
+		t.Errorf("allowed want 7 got %d", allowed)
+	}
+	//未配置采样的级别全部输出
+	for i := 0; i < 10; i++ {
+		if !s.allow(logrus.InfoLevel, "redisz.errorLog:20", "err:%v") {
+			t.Fatal("info level should not be sampled")
+		}
+	}
+	//不同模板单独计数
+	if !s.allow(logrus.ErrorLevel, "redisz.errorLog:20", "other:%v") {
+		t.Error("other template should be allowed")
+	}
+
+	s.flush()
+	if suppressed["err:%v"] != 16 {
+		t.Errorf("suppressed want 16 got %d", suppressed["err:%v"])
+	}
+
+	//新的周期重新计数
+	now = now.Add(time.Second)
+	if !s.allow(logrus.ErrorLevel, "redisz.errorLog:20", "err:%v") {
+		t.Error("new window should be allowed")
+	}
+	s.flush()
+	if suppressed["err:%v"] != 16 {
+		t.Errorf("suppressed want 16 got %d", suppressed["err:%v"])
+	}
+}
+
+func TestSampling(t *testing.T) {
+	closer := InitLog(testCtx,
+		"gov2",
+		nil,
+		Opt.Sampling(LevelError, SamplingConfig{Tick: time.Hour, First: 2, Thereafter: 0}),
+	)
+	defer closer()
+	reported := map[string]int{}
+	s := logSampler.Load()
+	s.report = func(key sampleKey, n int) {
+		reported[key.caller] += n
+	}
+	for i := 0; i < 10; i++ {
+		Errorf(testCtx, "redis err:%d", i)
+	}
+	//同一模板 不同调用点分别计数
+	for i := 0; i < 5; i++ {
+		Errorf(testCtx, "redis err:%d", i)
+	}
+	s.close()
+	if len(reported) != 2 {
+		t.Fatalf("callers want 2 got %v", reported)
+	}
+	total := 0
+	for caller, n := range reported {
+		if !strings.Contains(caller, "TestSampling") {
+			t.Errorf("caller=%s", caller)
+		}
+		total += n
+	}
+	if total != 11 {
+		t.Errorf("reported want 11 got %d", total)
+	}
+}
+
+func BenchmarkSampleAllow(b *testing.B) {
+	logrus.SetLevel(logrus.ErrorLevel)
+	s := newSampler(map[logrus.Level]SamplingConfig{logrus.ErrorLevel: {Tick: time.Hour, First: 1, Thereafter: 0}})
+	s.report = func(key sampleKey, n int) {}
+	logSampler.Store(s)
+	defer logSampler.Store(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Errorf(testCtx, "redis err:%d", i)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	ginPackage     = protogen.GoImportPath("github.com/gin-gonic/gin")
+	grpcPackage    = protogen.GoImportPath("google.golang.org/grpc")
+	errorzPackage  = protogen.GoImportPath("github.com/songlma/gobase/errorz")
+	httpzPackage   = protogen.GoImportPath("github.com/songlma/gobase/httpz")
+	rpczPackage    = protogen.GoImportPath("github.com/songlma/gobase/rpcz")
+)
+
+// xb/options.proto 中的扩展字段号
+const (
+	errorzOptionNumber     protowire.Number = 51001
+	alertOptionNumber      protowire.Number = 51002
+	httpStatusOptionNumber protowire.Number = 51003
+)
+
+// generateFile 生成 {name}_xb.pb.go 没有需要生成的内容时不生成文件
+func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	var enums []*protogen.Enum
+	for _, enum := range allEnums(file) {
+		if optionBool(enum.Desc.Options(), errorzOptionNumber) {
+			enums = append(enums, enum)
+		}
+	}
+	var services []*protogen.Service
+	for _, service := range file.Services {
+		if len(unaryMethods(service)) > 0 {
+			services = append(services, service)
+		}
+	}
+	if len(enums) == 0 && len(services) == 0 {
+		return nil
+	}
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_xb.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-xb. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	for _, enum := range enums {
+		generateErrorz(g, enum)
+	}
+	for _, service := range services {
+		generateRouter(g, service)
+		generateHttpClient(g, service)
+	}
+	return g
+}
+
+func allEnums(file *protogen.File) []*protogen.Enum {
+	enums := append([]*protogen.Enum(nil), file.Enums...)
+	var walk func(messages []*protogen.Message)
+	walk = func(messages []*protogen.Message) {
+		for _, message := range messages {
+			enums = append(enums, message.Enums...)
+			walk(message.Messages)
+		}
+	}
+	walk(file.Messages)
+	return enums
+}
+
+func unaryMethods(service *protogen.Service) []*protogen.Method {
+	var methods []*protogen.Method
+	for _, method := range service.Methods {
+		if !method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer() {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+/*
+*
+业务码常量 Code + 去掉枚举名前缀后的驼峰名 例如 ErrorCode.ERROR_CODE_POSTER_NOT_FOUND -> CodePosterNotFound
+值为0的枚举项不生成 设置了alert或http_status的枚举项在init中注册到errorz
+*/
+func generateErrorz(g *protogen.GeneratedFile, enum *protogen.Enum) {
+	prefix := camelToUpperSnake(enum.GoIdent.GoName) + "_"
+	type codeValue struct {
+		name       string
+		value      *protogen.EnumValue
+		alert      string
+		httpStatus int64
+	}
+	var values []codeValue
+	for _, value := range enum.Values {
+		if value.Desc.Number() == 0 {
+			continue
+		}
+		options := value.Desc.Options()
+		httpStatus, _ := optionVarint(options, httpStatusOptionNumber)
+		values = append(values, codeValue{
+			name:       "Code" + upperSnakeToCamel(strings.TrimPrefix(string(value.Desc.Name()), prefix)),
+			value:      value,
+			alert:      optionString(options, alertOptionNumber),
+			httpStatus: httpStatus,
+		})
+	}
+	if len(values) == 0 {
+		return
+	}
+	g.P("// ", enum.GoIdent.GoName, " errorz业务码")
+	g.P("const (")
+	for _, v := range values {
+		g.P(v.value.Comments.Leading, v.name, " = ", v.value.Desc.Number(), trailingComment(v.value.Comments.Trailing))
+	}
+	g.P(")")
+	g.P()
+	var registered bool
+	for _, v := range values {
+		if v.alert != "" || v.httpStatus != 0 {
+			registered = true
+		}
+	}
+	if !registered {
+		return
+	}
+	g.P("func init() {")
+	for _, v := range values {
+		if v.alert == "" && v.httpStatus == 0 {
+			continue
+		}
+		httpStatus := v.httpStatus
+		if httpStatus == 0 {
+			httpStatus = 200
+		}
+		g.P(errorzPackage.Ident("Register"), "(", v.name, ", ", httpStatus, ", ", strconv.Quote(v.alert), ")")
+	}
+	g.P("}")
+	g.P()
+}
+
+func generateRouter(g *protogen.GeneratedFile, service *protogen.Service) {
+	serverType := service.GoName + "Server"
+	descName := "_" + service.GoName + "_xbServiceDesc"
+	methods := unaryMethods(service)
+	for _, method := range methods {
+		handlerName := "_" + service.GoName + "_" + method.GoName + "_xbHandler"
+		fullMethod := "/" + string(service.Desc.FullName()) + "/" + string(method.Desc.Name())
+		g.P("func ", handlerName, "(srv interface{}, ctx ", contextPackage.Ident("Context"), ", dec func(interface{}) error, interceptor ", grpcPackage.Ident("UnaryServerInterceptor"), ") (interface{}, error) {")
+		g.P("in := new(", method.Input.GoIdent, ")")
+		g.P("if err := dec(in); err != nil { return nil, err }")
+		g.P("if interceptor == nil { return srv.(", serverType, ").", method.GoName, "(ctx, in) }")
+		g.P("info := &", grpcPackage.Ident("UnaryServerInfo"), "{")
+		g.P("Server: srv,")
+		g.P("FullMethod: ", strconv.Quote(fullMethod), ",")
+		g.P("}")
+		g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req interface{}) (interface{}, error) {")
+		g.P("return srv.(", serverType, ").", method.GoName, "(ctx, req.(*", method.Input.GoIdent, "))")
+		g.P("}")
+		g.P("return interceptor(ctx, in, info, handler)")
+		g.P("}")
+		g.P()
+	}
+	g.P("var ", descName, " = ", grpcPackage.Ident("ServiceDesc"), "{")
+	g.P("ServiceName: ", strconv.Quote(string(service.Desc.FullName())), ",")
+	g.P("HandlerType: (*", serverType, ")(nil),")
+	g.P("Methods: []", grpcPackage.Ident("MethodDesc"), "{")
+	for _, method := range methods {
+		g.P("{")
+		g.P("MethodName: ", strconv.Quote(string(method.Desc.Name())), ",")
+		g.P("Handler: _", service.GoName, "_", method.GoName, "_xbHandler,")
+		g.P("},")
+	}
+	g.P("},")
+	g.P("}")
+	g.P()
+	g.P("// Register", service.GoName, "Router 将", serverType, "的unary方法注册为 POST /", service.Desc.FullName(), "/{Method}")
+	g.P("// 请求经过gateway的拦截器链 响应为web.Result")
+	g.P("func Register", service.GoName, "Router(routes ", ginPackage.Ident("IRoutes"), ", gateway *", rpczPackage.Ident("Gateway"), ", srv ", serverType, ") {")
+	g.P("gateway.Handle(routes, &", descName, ", srv)")
+	g.P("}")
+	g.P()
+}
+
+func generateHttpClient(g *protogen.GeneratedFile, service *protogen.Service) {
+	clientType := service.GoName + "HttpClient"
+	g.P("// ", clientType, " 以V2请求格式调用Register", service.GoName, "Router注册的路由")
+	g.P("type ", clientType, " struct {")
+	g.P("client *", httpzPackage.Ident("Client"))
+	g.P("baseURL string")
+	g.P("}")
+	g.P()
+	g.P("// New", clientType, " baseURL为路由所在分组 例如 http://poster/api")
+	g.P("func New", clientType, "(client *", httpzPackage.Ident("Client"), ", baseURL string) *", clientType, " {")
+	g.P("return &", clientType, "{client: client, baseURL: baseURL}")
+	g.P("}")
+	g.P()
+	for _, method := range unaryMethods(service) {
+		path := "/" + string(service.Desc.FullName()) + "/" + string(method.Desc.Name())
+		g.P(method.Comments.Leading,
+			"func (c *", clientType, ") ", method.GoName, "(ctx ", contextPackage.Ident("Context"), ", in *", method.Input.GoIdent, ") (*", method.Output.GoIdent, ", error) {")
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("if err := ", rpczPackage.Ident("InvokeHTTP"), "(ctx, c.client, c.baseURL+", strconv.Quote(path), ", in, out); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+	}
+}
+
+func trailingComment(trailing protogen.Comments) string {
+	s := strings.TrimSuffix(trailing.String(), "\n")
+	if strings.Contains(s, "\n") {
+		//多行注释不放在行尾
+		return ""
+	}
+	return s
+}
+
+// optionBool 读取未注册的扩展选项 protoc传入的选项中自定义扩展保存在unknown字段
+func optionBool(options proto.Message, number protowire.Number) bool {
+	v, ok := optionVarint(options, number)
+	return ok && v != 0
+}
+
+func optionVarint(options proto.Message, number protowire.Number) (int64, bool) {
+	var value int64
+	var found bool
+	eachOption(options, number, func(typ protowire.Type, b []byte) int {
+		if typ != protowire.VarintType {
+			return protowire.ConsumeFieldValue(number, typ, b)
+		}
+		v, n := protowire.ConsumeVarint(b)
+		if n >= 0 {
+			value, found = int64(int32(v)), true
+		}
+		return n
+	})
+	return value, found
+}
+
+func optionString(options proto.Message, number protowire.Number) string {
+	var value string
+	eachOption(options, number, func(typ protowire.Type, b []byte) int {
+		if typ != protowire.BytesType {
+			return protowire.ConsumeFieldValue(number, typ, b)
+		}
+		v, n := protowire.ConsumeString(b)
+		if n >= 0 {
+			value = v
+		}
+		return n
+	})
+	return value
+}
+
+// eachOption 遍历unknown字段中字段号为number的值 重复出现时以最后一个为准
+func eachOption(options proto.Message, number protowire.Number, f func(typ protowire.Type, b []byte) int) {
+	if options == nil || !options.ProtoReflect().IsValid() {
+		return
+	}
+	b := options.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+		if num == number {
+			n = f(typ, b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+	}
+}
+
+// camelToUpperSnake ErrorCode -> ERROR_CODE
+func camelToUpperSnake(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(r)
+	}
+	return strings.ToUpper(sb.String())
+}
+
+// upperSnakeToCamel POSTER_NOT_FOUND -> PosterNotFound
+func upperSnakeToCamel(s string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(strings.ToLower(s), "_") {
+		if part == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
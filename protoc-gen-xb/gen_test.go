@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/songlma/gobase/protoc-gen-xb/xb"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// withUnknown 模拟protoc传入的自定义扩展选项
+func withUnknown[T proto.Message](options T, fields ...func([]byte) []byte) T {
+	var b []byte
+	for _, field := range fields {
+		b = field(b)
+	}
+	options.ProtoReflect().SetUnknown(b)
+	return options
+}
+
+func varintOption(number protowire.Number, v uint64) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	}
+}
+
+func stringOption(number protowire.Number, v string) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	}
+}
+
+func posterFile() *descriptorpb.FileDescriptorProto {
+	message := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+	enumValue := func(name string, number int32, options *descriptorpb.EnumValueOptions) *descriptorpb.EnumValueDescriptorProto {
+		return &descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Options: options}
+	}
+	method := func(name, input, output string, serverStreaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".poster." + input),
+			OutputType:      proto.String(".poster." + output),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("poster.proto"),
+		Package: proto.String("poster"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/poster/app/model;model")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("GetPosterReq", field("poster_id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64)),
+			message("GetPosterResp", field("title", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name:    proto.String("ErrorCode"),
+				Options: withUnknown(&descriptorpb.EnumOptions{}, varintOption(errorzOptionNumber, 1)),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					enumValue("ERROR_CODE_UNSPECIFIED", 0, nil),
+					enumValue("ERROR_CODE_POSTER_NOT_FOUND", 10001, withUnknown(&descriptorpb.EnumValueOptions{},
+						stringOption(alertOptionNumber, "海报不存在"), varintOption(httpStatusOptionNumber, 404))),
+					enumValue("ERROR_CODE_POSTER_EXPIRED", 10002, withUnknown(&descriptorpb.EnumValueOptions{},
+						stringOption(alertOptionNumber, "海报已过期"))),
+					enumValue("ERROR_CODE_RETRY", 10003, nil),
+				},
+			},
+			{
+				//未设置(xb.errorz)不生成
+				Name:  proto.String("Status"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{enumValue("STATUS_UNKNOWN", 0, nil), enumValue("STATUS_ONLINE", 1, nil)},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("PosterApi"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("GetPoster", "GetPosterReq", "GetPosterResp", false),
+					method("WatchPoster", "GetPosterReq", "GetPosterResp", true),
+				},
+			},
+			{
+				//只有流式方法 不生成
+				Name:   proto.String("PosterStream"),
+				Method: []*descriptorpb.MethodDescriptorProto{method("Watch", "GetPosterReq", "GetPosterResp", true)},
+			},
+		},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				//ErrorCode.ERROR_CODE_POSTER_NOT_FOUND
+				{Path: []int32{5, 0, 2, 1}, Span: []int32{10, 2, 40}, LeadingComments: proto.String(" 海报不存在或已删除\n")},
+				//PosterApi.GetPoster
+				{Path: []int32{6, 0, 2, 0}, Span: []int32{20, 2, 40}, LeadingComments: proto.String(" GetPoster 获取海报\n")},
+			},
+		},
+	}
+}
+
+func runGenerator(t *testing.T, files ...*descriptorpb.FileDescriptorProto) map[string]string {
+	var names []string
+	for _, file := range files {
+		names = append(names, file.GetName())
+	}
+	plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: names,
+		ProtoFile:      files,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range plugin.Files {
+		if f.Generate {
+			generateFile(plugin, f)
+		}
+	}
+	resp := plugin.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	generated := map[string]string{}
+	for _, file := range resp.File {
+		generated[file.GetName()] = file.GetContent()
+	}
+	return generated
+}
+
+func TestGenerateGolden(t *testing.T) {
+	generated := runGenerator(t, posterFile())
+	if len(generated) != 1 {
+		t.Fatalf("generated files=%d", len(generated))
+	}
+	content, ok := generated["example.com/poster/app/model/poster_xb.pb.go"]
+	if !ok {
+		t.Fatalf("generated=%v", generated)
+	}
+	golden := filepath.Join("testdata", "poster_xb.pb.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != string(want) {
+		t.Fatalf("generated code differs from %s, run go test -update\n%s", golden, content)
+	}
+}
+
+// posterServerStub 代替protoc-gen-go-grpc生成的PosterApiServer
+const posterServerStub = `package model
+
+import "context"
+
+type PosterApiServer interface {
+	GetPoster(context.Context, *GetPosterReq) (*GetPosterResp, error)
+}
+`
+
+// TestGoldenCompiles 在临时module中编译golden文件 依赖当前仓库(replace)和protoc-gen-go生成的message
+func TestGoldenCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip go build in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found")
+	}
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"poster.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{posterFile()},
+		Parameter:      proto.String("paths=source_relative"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gengo.GenerateFile(plugin, plugin.Files[0])
+	resp := plugin.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	golden, err := os.ReadFile(filepath.Join("testdata", "poster_xb.pb.go.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"go.mod": []byte("module example.com/poster\n\ngo 1.25.0\n\n" +
+			"require github.com/songlma/gobase v0.0.0\n\nreplace github.com/songlma/gobase => " + root + "\n"),
+		"go.sum":                      sum,
+		"app/model/poster.pb.go":      []byte(resp.File[0].GetContent()),
+		"app/model/poster_grpc.pb.go": []byte(posterServerStub),
+		"app/model/poster_xb.pb.go":   golden,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goBin, "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+}
+
+// TestOptionNumbers 生成器使用的字段号与xb/options.proto一致
+func TestOptionNumbers(t *testing.T) {
+	numbers := map[protowire.Number]protowire.Number{
+		errorzOptionNumber:     xb.E_Errorz.TypeDescriptor().Number(),
+		alertOptionNumber:      xb.E_Alert.TypeDescriptor().Number(),
+		httpStatusOptionNumber: xb.E_HttpStatus.TypeDescriptor().Number(),
+	}
+	for want, got := range numbers {
+		if want != got {
+			t.Fatalf("option number %d != %d", want, got)
+		}
+	}
+}
+
+func TestGenerateNothing(t *testing.T) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("empty.proto"),
+		Package:     proto.String("empty"),
+		Syntax:      proto.String("proto3"),
+		Options:     &descriptorpb.FileOptions{GoPackage: proto.String("example.com/empty;empty")},
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+	}
+	if generated := runGenerator(t, file); len(generated) != 0 {
+		t.Fatalf("generated=%v", generated)
+	}
+}
+
+func TestNameConversion(t *testing.T) {
+	if s := camelToUpperSnake("ErrorCode"); s != "ERROR_CODE" {
+		t.Fatal(s)
+	}
+	if s := upperSnakeToCamel("POSTER_NOT_FOUND_V2"); s != "PosterNotFoundV2" {
+		t.Fatal(s)
+	}
+}
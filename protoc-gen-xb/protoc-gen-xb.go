@@ -6,8 +6,23 @@ import (
 	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
+/*
+*
+protoc-gen-xb 为每个proto文件生成 {name}_xb.pb.go
+需要与protoc-gen-go(plugins=grpc)一起使用 生成代码引用其中的message和XxxServer接口
+
+	Register{Service}Router  将unary方法注册为gin POST路由 经过rpcz.Gateway的拦截器链
+	{Service}HttpClient      以V2请求格式调用上述路由
+	Code{Value}              option (xb.errorz) = true 的枚举生成errorz业务码常量
+
+示例:
+
+	protoc -I=./app/model/protobuf/ -I=$(go list -m -f '{{.Dir}}' github.com/songlma/gobase)/protoc-gen-xb \
+		--go_out=plugins=grpc:./app/model/ --xb_out=./app/model/ ./app/model/protobuf/*.proto
+*/
 func main() {
 	var (
 		flags        flag.FlagSet
@@ -16,7 +31,7 @@ func main() {
 	)
 	importRewriteFunc := func(importPath protogen.GoImportPath) protogen.GoImportPath {
 		switch importPath {
-		case "context", "fmt", "math":
+		case "context", "fmt", "math", ginPackage, grpcPackage, errorzPackage, httpzPackage, rpczPackage:
 			return importPath
 		}
 		if *importPrefix != "" {
@@ -33,24 +48,15 @@ func main() {
 			case "grpc":
 			case "":
 			default:
-				return fmt.Errorf("protoc-gen-go: unknown plugin %q", plugin)
+				return fmt.Errorf("protoc-gen-xb: unknown plugin %q", plugin)
 			}
 		}
-		//println(fmt.Sprintf("gen_Files:%+v", gen))
-		println("===========================================================")
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
 		for _, f := range gen.Files {
-			//println(fmt.Sprintf("f:%+v", f))
-			//println(fmt.Sprintf("f.Desc:%+v", f.Desc))
-			println(fmt.Sprintf("Services:%+v", f.Desc.Services()))
-			println("===========================================================")
-			println(fmt.Sprintf("fMessages:%+v", f.Desc.Messages()))
-			println("===========================================================")
-			println(fmt.Sprintf("FullName:%+v", f.Desc.FullName()))
-			println("===========================================================")
 			if !f.Generate {
 				continue
 			}
-
+			generateFile(gen, f)
 		}
 		return nil
 	})
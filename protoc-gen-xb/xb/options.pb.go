@@ -0,0 +1,121 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: xb/options.proto
+
+// protoc-gen-xb 自定义选项
+// protoc -I=$(go list -m -f '{{.Dir}}' github.com/songlma/gobase)/protoc-gen-xb ...
+
+package xb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+var file_xb_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.EnumOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         51001,
+		Name:          "xb.errorz",
+		Tag:           "varint,51001,opt,name=errorz",
+		Filename:      "xb/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.EnumValueOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         51002,
+		Name:          "xb.alert",
+		Tag:           "bytes,51002,opt,name=alert",
+		Filename:      "xb/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.EnumValueOptions)(nil),
+		ExtensionType: (*int32)(nil),
+		Field:         51003,
+		Name:          "xb.http_status",
+		Tag:           "varint,51003,opt,name=http_status",
+		Filename:      "xb/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.EnumOptions.
+var (
+	// 为枚举生成errorz业务码常量 例如 option (xb.errorz) = true;
+	//
+	// optional bool errorz = 51001;
+	E_Errorz = &file_xb_options_proto_extTypes[0]
+)
+
+// Extension fields to descriptorpb.EnumValueOptions.
+var (
+	// 默认提示 与http_status一起通过errorz.Register注册
+	//
+	// optional string alert = 51002;
+	E_Alert = &file_xb_options_proto_extTypes[1]
+	// 业务码对应的http状态码 默认200
+	//
+	// optional int32 http_status = 51003;
+	E_HttpStatus = &file_xb_options_proto_extTypes[2]
+)
+
+var File_xb_options_proto protoreflect.FileDescriptor
+
+const file_xb_options_proto_rawDesc = "" +
+	"\n" +
+	"\x10xb/options.proto\x12\x02xb\x1a google/protobuf/descriptor.proto:6\n" +
+	"\x06errorz\x12\x1c.google.protobuf.EnumOptions\x18\xb9\x8e\x03 \x01(\bR\x06errorz:9\n" +
+	"\x05alert\x12!.google.protobuf.EnumValueOptions\x18\xba\x8e\x03 \x01(\tR\x05alert:D\n" +
+	"\vhttp_status\x12!.google.protobuf.EnumValueOptions\x18\xbb\x8e\x03 \x01(\x05R\n" +
+	"httpStatusB,Z*github.com/songlma/gobase/protoc-gen-xb/xbb\x06proto3"
+
+var file_xb_options_proto_goTypes = []any{
+	(*descriptorpb.EnumOptions)(nil),      // 0: google.protobuf.EnumOptions
+	(*descriptorpb.EnumValueOptions)(nil), // 1: google.protobuf.EnumValueOptions
+}
+var file_xb_options_proto_depIdxs = []int32{
+	0, // 0: xb.errorz:extendee -> google.protobuf.EnumOptions
+	1, // 1: xb.alert:extendee -> google.protobuf.EnumValueOptions
+	1, // 2: xb.http_status:extendee -> google.protobuf.EnumValueOptions
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	0, // [0:3] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_xb_options_proto_init() }
+func file_xb_options_proto_init() {
+	if File_xb_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_xb_options_proto_rawDesc), len(file_xb_options_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   0,
+			NumExtensions: 3,
+			NumServices:   0,
+		},
+		GoTypes:           file_xb_options_proto_goTypes,
+		DependencyIndexes: file_xb_options_proto_depIdxs,
+		ExtensionInfos:    file_xb_options_proto_extTypes,
+	}.Build()
+	File_xb_options_proto = out.File
+	file_xb_options_proto_goTypes = nil
+	file_xb_options_proto_depIdxs = nil
+}
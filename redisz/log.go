@@ -3,21 +3,20 @@ package redisz
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/logger"
 )
 
+// errorLog redis 故障时会产生大量相同日志 模板按tag生成 logger按模板+caller采样去重 不同命令的错误分别采样
 func errorLog(ctx context.Context, tag string, err error, args ...interface{}) {
-	traceId, _ := contextz.GetTraceID(ctx)
 	var argInfo []string
 	for _, arg := range args {
 		argInfo = append(argInfo, fmt.Sprintf("%v", arg))
 	}
-	log.Printf("err:%v;trace_id:%s;tag:%s;%s", err, traceId, tag, strings.Join(argInfo, ";"))
+	logger.Errorf(ctx, errorLogTemplate(tag), err, strings.Join(argInfo, ";"))
 	span, _ := opentracing.StartSpanFromContext(ctx, tag)
 	ext.Error.Set(span, true)
 	ext.Component.Set(span, "redis")
@@ -27,3 +26,8 @@ func errorLog(ctx context.Context, tag string, err error, args ...interface{}) {
 	span.LogKV("message", fmt.Sprintf("%v", err))
 	span.Finish()
 }
+
+// errorLogTemplate tag中的%转义 tag不会被当作格式化参数解析
+func errorLogTemplate(tag string) string {
+	return "redis " + strings.ReplaceAll(tag, "%", "%%") + " err:%v;%s"
+}
@@ -90,8 +90,8 @@ err示例：
 func (conn *Conn) do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
 	if !conn.opentracing {
 		reply, err = conn.redisConn.Do(commandName, args...)
-		if err != nil {
-			errorLog(ctx, "redisConnDo", err, commandName, args)
+		if err != nil && !isNoScript(err) {
+			errorLog(ctx, "redisConnDo "+commandName, err, args)
 		}
 		return reply, err
 	}
@@ -101,8 +101,8 @@ func (conn *Conn) do(ctx context.Context, commandName string, args ...interface{
 	ext.Component.Set(span, "redis")
 	span.LogFields(log.Object("args", args))
 	reply, err = conn.redisConn.Do(commandName, args...)
-	if err != nil {
-		errorLog(ctx, "redisConnDo", err, commandName, args)
+	if err != nil && !isNoScript(err) {
+		errorLog(ctx, "redisConnDo "+commandName, err, args)
 		ext.Error.Set(span, true)
 		span.LogKV("event", "error")
 		span.LogKV("error.kind", "redis")
@@ -1159,3 +1159,12 @@ func TestConn_HLen(t *testing.T) {
 		t.Error(hLen)
 	}
 }
+
+func TestErrorLogTemplate(t *testing.T) {
+	if got := errorLogTemplate("redisConnDo GET"); got != "redis redisConnDo GET err:%v;%s" {
+		t.Fatal(got)
+	}
+	if got := fmt.Sprintf(errorLogTemplate("bad%d"), "e", "a"); got != "redis bad%d err:e;a" {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,67 @@
+package redisz
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+/*
+*
+Script lua脚本
+优先使用EVALSHA执行 redis中没有缓存脚本时使用EVAL
+示例:
+
+	var incrScript = redisz.NewScript(1, `return redis.call('INCRBY', KEYS[1], ARGV[1])`)
+	reply, err := redisz.Int64(conn.Eval(ctx, incrScript, key, 2))
+*/
+type Script struct {
+	keyCount int
+	src      string
+	hash     string
+}
+
+// NewScript keyCount为KEYS的数量 keysAndArgs中前keyCount个为KEYS 其余为ARGV
+func NewScript(keyCount int, src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{
+		keyCount: keyCount,
+		src:      src,
+		hash:     hex.EncodeToString(sum[:]),
+	}
+}
+
+// Hash 脚本的sha1
+func (script *Script) Hash() string {
+	return script.hash
+}
+
+func (script *Script) args(spec string, keysAndArgs []interface{}) []interface{} {
+	args := make([]interface{}, 0, len(keysAndArgs)+2)
+	args = append(args, spec, script.keyCount)
+	return append(args, keysAndArgs...)
+}
+
+/*
+*
+执行lua脚本
+reply
+
+	脚本返回值 可使用 redisz.Int64s redisz.Values 等转换
+*/
+func (conn *Conn) Eval(ctx context.Context, script *Script, keysAndArgs ...interface{}) (reply interface{}, err error) {
+	reply, err = conn.do(ctx, "EVALSHA", script.args(script.hash, keysAndArgs)...)
+	if isNoScript(err) {
+		reply, err = conn.do(ctx, "EVAL", script.args(script.src, keysAndArgs)...)
+	}
+	return reply, err
+}
+
+// isNoScript EVALSHA 脚本未缓存
+func isNoScript(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(e), "NOSCRIPT")
+}
@@ -0,0 +1,59 @@
+package redisz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/trace/tracetest"
+)
+
+// replyConn 不连接redis 按命令返回固定结果
+type replyConn struct {
+	redis.Conn
+	replies map[string]interface{}
+}
+
+func (conn *replyConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply := conn.replies[commandName]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (conn *replyConn) Close() error {
+	return nil
+}
+
+func TestConn_Opentracing(t *testing.T) {
+	recorder := tracetest.Install(t)
+	conn := &Conn{
+		redisConn: &replyConn{replies: map[string]interface{}{
+			"ping": "PONG",
+			"TYPE": errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		}},
+		opentracing: true,
+	}
+	parent, ctx := opentracing.StartSpanFromContext(context.Background(), "parent")
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Type(ctx, "key"); err == nil {
+		t.Fatal("TYPE should fail")
+	}
+	parent.Finish()
+
+	parentSpan := recorder.AssertSpan(t, "parent")
+	recorder.AssertSpan(t, "Redis ping").
+		ChildOf(parentSpan).
+		HasTag("component", "redis").
+		NoError()
+	recorder.AssertSpan(t, "Redis TYPE").
+		ChildOf(parentSpan).
+		HasError().
+		HasLog("error.kind", "redis").
+		HasLog("args", nil)
+}
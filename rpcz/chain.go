@@ -0,0 +1,55 @@
+package rpcz
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+/*
+*
+WithUnaryClientInterceptor 客户端拦截器链 按顺序执行
+示例:
+
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		rpcz.WithUnaryClientInterceptor(
+			rpcz.OpenTracingUnaryClientInterceptor(opentracing.GlobalTracer()),
+			rpcz.SignUnaryClientInterceptor(rpcz.SignOpt.ServiceName("poster")),
+			rpcz.LogUnaryClientInterceptor(),
+			rpcz.PanicUnaryClientInterceptor(),
+		),
+	)
+*/
+func WithUnaryClientInterceptor(interceptors ...grpc.UnaryClientInterceptor) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(interceptors...)
+}
+
+// WithStreamClientInterceptor 客户端流拦截器链 按顺序执行
+func WithStreamClientInterceptor(interceptors ...grpc.StreamClientInterceptor) grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(interceptors...)
+}
+
+// WithUnaryServerChain 服务端拦截器链 按顺序执行
+func WithUnaryServerChain(interceptors ...grpc.UnaryServerInterceptor) grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(interceptors...)
+}
+
+// WithStreamServerChain 服务端流拦截器链 按顺序执行
+func WithStreamServerChain(interceptors ...grpc.StreamServerInterceptor) grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(interceptors...)
+}
+
+// wrappedServerStream 替换服务端流的ctx
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *wrappedServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func wrapServerStream(stream grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &wrappedServerStream{ServerStream: stream, ctx: ctx}
+}
@@ -0,0 +1,238 @@
+package rpcz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// 负载均衡策略
+const (
+	PolicyRoundRobin = "round_robin"
+	PolicyPickFirst  = "pick_first"
+)
+
+// 静态地址列表使用的resolver scheme
+const staticScheme = "rpcz-static"
+
+// TargetConfig 下游服务配置
+type TargetConfig struct {
+	//服务地址 例如 poster:8081 dns:///poster:8081 未带scheme时使用dns解析
+	Target string
+	//静态地址列表 不为空时忽略Target的解析结果
+	Addrs   []string
+	Policy  string        //负载均衡策略 默认round_robin
+	Timeout time.Duration //ctx未设置deadline时的默认超时 默认使用ClientPoolConfig.Timeout
+	Secret  string        //签名密钥 为空时使用ClientPoolConfig.Secret 仍为空时只传递服务名
+	//追加在默认拦截器链之后
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+	DialOptions        []grpc.DialOption
+}
+
+// ClientPoolConfig 连接池配置
+type ClientPoolConfig struct {
+	ServiceName string             //调用方服务名 签名使用
+	Tracer      opentracing.Tracer //为nil时使用opentracing.GlobalTracer()
+	Timeout     time.Duration      //默认超时 默认3秒
+	Keepalive   keepalive.ClientParameters
+	//TargetConfig.Secret为空时按下游名称获取签名密钥 为nil时不签名
+	Secret func(name string) string
+}
+
+// NewClientPoolConfig 默认配置 keepalive每30秒探测一次 ServerApp允许该频率
+func NewClientPoolConfig(serviceName string) ClientPoolConfig {
+	return ClientPoolConfig{
+		ServiceName: serviceName,
+		Timeout:     3 * time.Second,
+		Keepalive: keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		},
+	}
+}
+
+/*
+*
+ClientPool 按名称缓存grpc连接 首次Conn时建立 并发安全
+默认拦截器链 Panic -> Timeout -> OpenTracing -> Sign -> Log -> Errorz
+连接状态通过grpc_client_connection_state指标上报
+示例:
+
+	var Pool = rpcz.NewClientPool(rpcz.NewClientPoolConfig("poster"))
+
+	Pool.Register("user", rpcz.TargetConfig{Target: config.GetString("config.rpc.user")})
+	conn, errz := Pool.Conn(ctx, "user")
+	if errz != nil {
+		return errz
+	}
+	resp, err := model.NewUserInnerClient(conn).GetUser(ctx, req)
+*/
+type ClientPool struct {
+	conf ClientPoolConfig
+
+	mu      sync.Mutex
+	targets map[string]TargetConfig
+	conns   map[string]*grpc.ClientConn
+	closed  bool
+}
+
+func NewClientPool(conf ClientPoolConfig) *ClientPool {
+	if conf.Timeout <= 0 {
+		conf.Timeout = 3 * time.Second
+	}
+	return &ClientPool{
+		conf:    conf,
+		targets: make(map[string]TargetConfig),
+		conns:   make(map[string]*grpc.ClientConn),
+	}
+}
+
+// Register 注册下游服务 已建立的连接不受影响
+func (pool *ClientPool) Register(name string, target TargetConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.targets[name] = target
+}
+
+// Conn 获取连接 未Register的name作为Target使用默认配置
+func (pool *ClientPool) Conn(ctx context.Context, name string) (*grpc.ClientConn, errorz.Error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.closed {
+		return nil, errorz.New(-1, "rpcz: client pool closed")
+	}
+	if conn, ok := pool.conns[name]; ok {
+		return conn, nil
+	}
+	target, ok := pool.targets[name]
+	if !ok {
+		target = TargetConfig{Target: name}
+	}
+	if target.Secret == "" && pool.conf.Secret != nil {
+		target.Secret = pool.conf.Secret(name)
+	}
+	conn, err := pool.dial(target)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("ClientPool dial %s err:%v", name, err))
+		return nil, errorz.FromStd(err)
+	}
+	pool.conns[name] = conn
+	go watchState(name, conn)
+	return conn, nil
+}
+
+// Close 关闭所有连接 之后Conn返回错误
+func (pool *ClientPool) Close() error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.closed = true
+	var firstErr error
+	for name, conn := range pool.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(pool.conns, name)
+	}
+	return firstErr
+}
+
+func (pool *ClientPool) dial(target TargetConfig) (*grpc.ClientConn, error) {
+	policy := target.Policy
+	if policy == "" {
+		policy = PolicyRoundRobin
+	}
+	if policy != PolicyRoundRobin && policy != PolicyPickFirst {
+		return nil, fmt.Errorf("rpcz: unknown balancing policy %q", policy)
+	}
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = pool.conf.Timeout
+	}
+	tracer := pool.conf.Tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+	signOpts := []SignOption{SignOpt.ServiceName(pool.conf.ServiceName), SignOpt.Secret(target.Secret)}
+	unary := append([]grpc.UnaryClientInterceptor{
+		PanicUnaryClientInterceptor(),
+		TimeoutUnaryClientInterceptor(timeout),
+		OpenTracingUnaryClientInterceptor(tracer),
+		SignUnaryClientInterceptor(signOpts...),
+		LogUnaryClientInterceptor(),
+		ErrorzUnaryClientInterceptor(),
+	}, target.UnaryInterceptors...)
+	stream := append([]grpc.StreamClientInterceptor{
+		PanicStreamClientInterceptor(),
+		OpenTracingStreamClientInterceptor(tracer),
+		SignStreamClientInterceptor(signOpts...),
+		LogStreamClientInterceptor(),
+		ErrorzStreamClientInterceptor(),
+	}, target.StreamInterceptors...)
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig":[{"%s":{}}]}`, policy)),
+		WithUnaryClientInterceptor(unary...),
+		WithStreamClientInterceptor(stream...),
+	}
+	if pool.conf.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(pool.conf.Keepalive))
+	}
+	addr := target.Target
+	if len(target.Addrs) > 0 {
+		r := manual.NewBuilderWithScheme(staticScheme)
+		addresses := make([]resolver.Address, 0, len(target.Addrs))
+		for _, a := range target.Addrs {
+			addresses = append(addresses, resolver.Address{Addr: a})
+		}
+		r.InitialState(resolver.State{Addresses: addresses})
+		opts = append(opts, grpc.WithResolvers(r))
+		addr = staticScheme + ":///" + target.Target
+	} else if !strings.Contains(addr, ":///") {
+		addr = "dns:///" + addr
+	}
+	opts = append(opts, target.DialOptions...)
+	return grpc.NewClient(addr, opts...)
+}
+
+// TimeoutUnaryClientInterceptor ctx未设置deadline时添加默认超时
+func TimeoutUnaryClientInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// watchState 上报连接状态 连接关闭后退出
+func watchState(name string, conn *grpc.ClientConn) {
+	registerMetrics()
+	state := conn.GetState()
+	for {
+		connStateGauge.WithLabelValues(name).Set(float64(state))
+		connStateTransitions.WithLabelValues(name, state.String()).Inc()
+		if state == connectivity.Shutdown {
+			return
+		}
+		if !conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+		state = conn.GetState()
+	}
+}
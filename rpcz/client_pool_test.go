@@ -0,0 +1,191 @@
+package rpcz
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/songlma/gobase/httpz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// countServer 记录请求次数 service为slow时阻塞到ctx结束
+type countServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	calls atomic.Int64
+}
+
+func (server *countServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	server.calls.Add(1)
+	if req.Service == "slow" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func startCountServer(t *testing.T) (*countServer, string) {
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = mocktracer.New()
+	return startCountServerWithConfig(t, conf)
+}
+
+func startCountServerWithConfig(t *testing.T, conf ServerConfig) (*countServer, string) {
+	ctx := context.Background()
+	rpcApp := NewServerApp(ctx, conf)
+	server := &countServer{}
+	desc := grpc_health_v1.Health_ServiceDesc
+	desc.ServiceName = "test.Count"
+	rpcApp.Server().RegisterService(&desc, server)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = rpcApp.Start(ctx)
+	}()
+	for rpcApp.Addr() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(func() {
+		_ = rpcApp.Stop(ctx)
+		<-done
+	})
+	return server, rpcApp.Addr().String()
+}
+
+func newTestPool(t *testing.T) *ClientPool {
+	conf := NewClientPoolConfig("poster")
+	conf.Tracer = mocktracer.New()
+	pool := NewClientPool(conf)
+	t.Cleanup(func() {
+		_ = pool.Close()
+	})
+	return pool
+}
+
+func callCount(ctx context.Context, pool *ClientPool, name, service string) error {
+	conn, errz := pool.Conn(ctx, name)
+	if errz != nil {
+		return errz
+	}
+	resp := new(grpc_health_v1.HealthCheckResponse)
+	return conn.Invoke(ctx, "/test.Count/Check", &grpc_health_v1.HealthCheckRequest{Service: service}, resp)
+}
+
+func TestClientPoolRoundRobin(t *testing.T) {
+	server1, addr1 := startCountServer(t)
+	server2, addr2 := startCountServer(t)
+	pool := newTestPool(t)
+	pool.Register("count", TargetConfig{Addrs: []string{addr1, addr2}, Policy: PolicyRoundRobin})
+	ctx := context.Background()
+	for i := 0; i < 200 && (server1.calls.Load() == 0 || server2.calls.Load() == 0); i++ {
+		if err := callCount(ctx, pool, "count", ""); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if server1.calls.Load() == 0 || server2.calls.Load() == 0 {
+		t.Fatalf("calls server1=%d server2=%d", server1.calls.Load(), server2.calls.Load())
+	}
+}
+
+func TestClientPoolPickFirst(t *testing.T) {
+	server1, addr1 := startCountServer(t)
+	server2, addr2 := startCountServer(t)
+	pool := newTestPool(t)
+	pool.Register("count", TargetConfig{Addrs: []string{addr1, addr2}, Policy: PolicyPickFirst})
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		if err := callCount(ctx, pool, "count", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server1.calls.Load() != 20 || server2.calls.Load() != 0 {
+		t.Fatalf("calls server1=%d server2=%d", server1.calls.Load(), server2.calls.Load())
+	}
+}
+
+func TestClientPoolConcurrentConn(t *testing.T) {
+	_, addr := startCountServer(t)
+	pool := newTestPool(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	conns := make([]*grpc.ClientConn, 20)
+	for i := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, errz := pool.Conn(ctx, addr)
+			if errz != nil {
+				t.Error(errz)
+				return
+			}
+			conns[i] = conn
+		}()
+	}
+	wg.Wait()
+	for _, conn := range conns {
+		if conn != conns[0] {
+			t.Fatal("Conn should return the cached connection")
+		}
+	}
+	if err := callCount(ctx, pool, addr, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, errz := pool.Conn(ctx, addr); errz == nil {
+		t.Fatal("Conn after Close should fail")
+	}
+}
+
+func TestClientPoolTimeout(t *testing.T) {
+	_, addr := startCountServer(t)
+	pool := newTestPool(t)
+	pool.Register("count", TargetConfig{Target: addr, Timeout: 50 * time.Millisecond})
+	err := callCount(context.Background(), pool, "count", "slow")
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Fatalf("code=%v err=%v", code, err)
+	}
+}
+
+func TestClientPoolBadPolicy(t *testing.T) {
+	pool := newTestPool(t)
+	pool.Register("bad", TargetConfig{Target: "127.0.0.1:1", Policy: "random"})
+	if _, errz := pool.Conn(context.Background(), "bad"); errz == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestClientPoolSecret(t *testing.T) {
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = mocktracer.New()
+	conf.SignConfig = &httpz.SignConfig{Secrets: map[string]string{"poster": "secret"}}
+	_, addr := startCountServerWithConfig(t, conf)
+	pool := newTestPool(t)
+	pool.conf.Secret = func(name string) string {
+		if name == "signed" {
+			return "secret"
+		}
+		return ""
+	}
+	pool.Register("unsigned", TargetConfig{Target: addr})
+	pool.Register("signed", TargetConfig{Target: addr})
+	ctx := context.Background()
+	for name, code := range map[string]codes.Code{"unsigned": codes.Unauthenticated, "signed": codes.OK} {
+		conn, errz := pool.Conn(ctx, name)
+		if errz != nil {
+			t.Fatal(errz)
+		}
+		_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if status.Code(err) != code {
+			t.Fatalf("%s err=%v", name, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package rpcz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/trace"
+	"github.com/songlma/gobase/web"
+	uber "github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var gatewayUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// 字段名与proto一致(下划线) 零值字段也输出 便于客户端解析
+var gatewayMarshal = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+type gatewayMethod struct {
+	service string
+	impl    interface{}
+	desc    grpc.MethodDesc
+}
+
+/*
+*
+Gateway 将grpc服务的unary方法挂载为gin POST路由 实现grpc.ServiceRegistrar
+路由为 /{package.Service}/{Method} 请求体为json(支持V2请求格式) 按protojson解码为请求message
+在进程内经过拦截器链调用服务实现 响应为web.Result content为protojson编码的响应message
+只有DefaultGatewayHeaders和ForwardHeaders指定的http header作为incoming metadata传入
+Cookie Authorization等header不会传给服务实现 流式方法不挂载
+示例:
+
+	gateway := rpcApp.NewGateway()
+	model.RegisterPosterApiServer(rpcApp.Server(), apiServer)
+	model.RegisterPosterApiServer(gateway, apiServer)
+	gateway.Mount(ginEngine.Group("api/"))
+*/
+type Gateway struct {
+	interceptor grpc.UnaryServerInterceptor
+	headers     []string
+
+	mu      sync.Mutex
+	methods []gatewayMethod
+}
+
+// DefaultGatewayHeaders 默认传入metadata的http header 只包含链路追踪相关的header
+var DefaultGatewayHeaders = []string{
+	uber.TraceContextHeaderName,
+	trace.TraceParentHeader,
+	trace.TraceStateHeader,
+	trace.B3SingleHeader,
+	trace.B3TraceIdHeader,
+	trace.B3SpanIdHeader,
+	trace.B3ParentSpanHeader,
+	trace.B3SampledHeader,
+	trace.B3FlagsHeader,
+	trace.TraceIdKey,
+	trace.ParentSpanIDKey,
+}
+
+// NewGateway interceptors按顺序执行 与grpc.ChainUnaryInterceptor一致
+func NewGateway(interceptors ...grpc.UnaryServerInterceptor) *Gateway {
+	gateway := &Gateway{interceptor: chainUnaryServer(interceptors)}
+	gateway.ForwardHeaders(DefaultGatewayHeaders...)
+	return gateway
+}
+
+/*
+*
+NewGateway 使用ServerApp的拦截器链 但不包含Sign
+Gateway挂载在对外的http路由上 调用方不是内部服务 不校验内部签名
+*/
+func (app *ServerApp) NewGateway() *Gateway {
+	return NewGateway(app.gatewayUnary...)
+}
+
+// ForwardHeaders 追加传入metadata的http header 需在Mount之前调用
+func (gateway *Gateway) ForwardHeaders(headers ...string) *Gateway {
+	for _, header := range headers {
+		gateway.headers = append(gateway.headers, http.CanonicalHeaderKey(header))
+	}
+	return gateway
+}
+
+// RegisterService 实现grpc.ServiceRegistrar 由生成的RegisterXxxServer调用
+func (gateway *Gateway) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	for _, method := range desc.Methods {
+		gateway.methods = append(gateway.methods, gatewayMethod{
+			service: desc.ServiceName,
+			impl:    impl,
+			desc:    method,
+		})
+	}
+}
+
+// Mount 挂载已注册的方法
+func (gateway *Gateway) Mount(routes gin.IRoutes) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	for _, method := range gateway.methods {
+		routes.POST("/"+method.service+"/"+method.desc.MethodName, gateway.handler(method))
+	}
+}
+
+/*
+*
+Handle 直接挂载一个服务 不加入已注册列表
+protoc-gen-xb生成的RegisterXxxRouter使用 desc中的Handler由生成代码提供
+*/
+func (gateway *Gateway) Handle(routes gin.IRoutes, desc *grpc.ServiceDesc, impl interface{}) {
+	for _, method := range desc.Methods {
+		method := gatewayMethod{service: desc.ServiceName, impl: impl, desc: method}
+		routes.POST("/"+method.service+"/"+method.desc.MethodName, gateway.handler(method))
+	}
+}
+
+func (gateway *Gateway) handler(method gatewayMethod) gin.HandlerFunc {
+	fullMethod := "/" + method.service + "/" + method.desc.MethodName
+	return func(ginCtx *gin.Context) {
+		ctx := gateway.incomingContext(ginCtx)
+		ctx = grpc.NewContextWithServerTransportStream(ctx, &gatewayTransportStream{method: fullMethod})
+		resp, err := method.desc.Handler(method.impl, ctx, func(req interface{}) error {
+			return decodeRequest(ginCtx, req)
+		}, gateway.interceptor)
+		respondGateway(ginCtx, resp, err)
+	}
+}
+
+// incomingContext 允许转发的http header转为incoming metadata
+func (gateway *Gateway) incomingContext(ginCtx *gin.Context) context.Context {
+	md := metadata.MD{}
+	for _, key := range gateway.headers {
+		if values := ginCtx.Request.Header.Values(key); len(values) > 0 {
+			md[strings.ToLower(key)] = values
+		}
+	}
+	return metadata.NewIncomingContext(ginCtx.Request.Context(), md)
+}
+
+func decodeRequest(ginCtx *gin.Context, req interface{}) error {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return web.BindError(ginCtx, fmt.Errorf("rpcz: %T is not a proto message", req))
+	}
+	body, err := web.GetParams(ginCtx)
+	if err != nil {
+		return web.BindError(ginCtx, err)
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	var envelope struct {
+		Version string          `json:"version"`
+		Params  json.RawMessage `json:"params"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Version == "V2" {
+		body = envelope.Params
+	}
+	if err = gatewayUnmarshal.Unmarshal(body, message); err != nil {
+		return web.BindError(ginCtx, err)
+	}
+	return nil
+}
+
+/*
+*
+errorz错误(包括ToStatus转换的status)和服务端错误通过web.Respond输出
+其他grpc status(如签名失败)按codes映射http状态码 code为-1 grpc code与errorz业务码不在同一空间 不直接输出
+*/
+func respondGateway(ginCtx *gin.Context, resp interface{}, err error) {
+	if err != nil {
+		err = FromStatusError(err)
+		st, ok := status.FromError(err)
+		if httpStatus := HTTPStatusFromCode(st.Code()); ok && errorz.CodeOf(err) == -1 && httpStatus < http.StatusInternalServerError {
+			web.RespondResult(ginCtx, httpStatus, &web.Result{
+				Code:    -1,
+				Msg:     st.Message(),
+				TraceId: trace.TraceIDFromContext(ginCtx.Request.Context()),
+			})
+			return
+		}
+		web.Respond(ginCtx, nil, err)
+		return
+	}
+	message, ok := resp.(proto.Message)
+	if !ok || strings.Contains(ginCtx.GetHeader("Accept"), web.MIMEProtobuf) {
+		web.Respond(ginCtx, resp, nil)
+		return
+	}
+	body, err := gatewayMarshal.Marshal(message)
+	if err != nil {
+		web.Respond(ginCtx, nil, errorz.FromStd(err))
+		return
+	}
+	web.Respond(ginCtx, json.RawMessage(body), nil)
+}
+
+// HTTPStatusFromCode grpc code对应的http状态码
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// chainUnaryServer 与grpc.ChainUnaryInterceptor顺序一致
+func chainUnaryServer(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptors[0](ctx, req, info, chainedHandler(interceptors, 0, info, handler))
+	}
+}
+
+func chainedHandler(interceptors []grpc.UnaryServerInterceptor, current int, info *grpc.UnaryServerInfo, finalHandler grpc.UnaryHandler) grpc.UnaryHandler {
+	if current == len(interceptors)-1 {
+		return finalHandler
+	}
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptors[current+1](ctx, req, info, chainedHandler(interceptors, current+1, info, finalHandler))
+	}
+}
+
+// gatewayTransportStream 使服务实现中的grpc.Method可用 SetHeader等忽略
+type gatewayTransportStream struct {
+	method string
+}
+
+func (stream *gatewayTransportStream) Method() string {
+	return stream.method
+}
+
+func (stream *gatewayTransportStream) SetHeader(md metadata.MD) error {
+	return nil
+}
+
+func (stream *gatewayTransportStream) SendHeader(md metadata.MD) error {
+	return nil
+}
+
+func (stream *gatewayTransportStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
@@ -0,0 +1,175 @@
+package rpcz
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/web"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestGateway(t *testing.T) (*gin.Engine, *healthServer) {
+	gin.SetMode(gin.TestMode)
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = mocktracer.New()
+	conf.SignConfig = &httpz.SignConfig{Secrets: map[string]string{"poster": "secret"}}
+	server := &healthServer{}
+	gateway := NewServerApp(t.Context(), conf).NewGateway()
+	grpc_health_v1.RegisterHealthServer(gateway, server)
+	engine := gin.New()
+	gateway.Mount(engine.Group("api/"))
+	return engine, server
+}
+
+func gatewayPost(t *testing.T, engine *gin.Engine, body string, header map[string]string) (*httptest.ResponseRecorder, web.Result) {
+	req := httptest.NewRequest(http.MethodPost, "/api/grpc.health.v1.Health/Check", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	var result web.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("body=%s err=%v", w.Body.String(), err)
+	}
+	return w, result
+}
+
+func TestGateway(t *testing.T) {
+	engine, _ := newTestGateway(t)
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   int64
+	}{
+		{name: "ok", body: `{"service":"ok"}`, status: http.StatusOK, code: 0},
+		{name: "v2", body: `{"version":"V2","params":{"service":"ok"}}`, status: http.StatusOK, code: 0},
+		{name: "errorz", body: `{"service":"errorz"}`, status: http.StatusOK, code: 10001},
+		{name: "panic", body: `{"service":"panic"}`, status: http.StatusInternalServerError, code: -1},
+		{name: "bad json", body: `{"service":1}`, status: http.StatusBadRequest, code: web.CodeParamsErr},
+		{name: "grpc status", body: `{"service":"denied"}`, status: http.StatusForbidden, code: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Gateway不校验内部签名 未签名的请求也能调用
+			w, result := gatewayPost(t, engine, tt.body, nil)
+			if w.Code != tt.status || result.Code != tt.code {
+				t.Fatalf("status=%d code=%d body=%s", w.Code, result.Code, w.Body.String())
+			}
+			if tt.code != 0 {
+				return
+			}
+			content, _ := json.Marshal(result.Content)
+			if string(content) != `{"status":"SERVING"}` {
+				t.Fatalf("content=%s", content)
+			}
+		})
+	}
+}
+
+func TestGatewayForwardHeaders(t *testing.T) {
+	engine, server := newTestGateway(t)
+	header := map[string]string{
+		"Traceparent":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"Cookie":        "session=x",
+		"Authorization": "Bearer x",
+		"X-Custom":      "x",
+	}
+	for key, value := range signedHeader(t, "secret") {
+		header[key] = value
+	}
+	if w, result := gatewayPost(t, engine, `{"service":"ok"}`, header); w.Code != http.StatusOK || result.Code != 0 {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	md := server.incomingMD()
+	if got := md.Get("traceparent"); len(got) != 1 || got[0] != header["Traceparent"] {
+		t.Fatalf("traceparent=%v", got)
+	}
+	for _, key := range []string{"cookie", "authorization", "x-custom", serviceNameKey, signKey} {
+		if got := md.Get(key); len(got) != 0 {
+			t.Fatalf("%s forwarded: %v", key, got)
+		}
+	}
+	// 服务名只来自http中间件校验后的ctx 不能通过header伪造
+	if name := server.requestServiceName(); name != "" {
+		t.Fatalf("service name=%q", name)
+	}
+}
+
+// signedHeader 按SignUnaryClientInterceptor的规则生成header
+func signedHeader(t *testing.T, secret string) map[string]string {
+	options := newSignOptions([]SignOption{SignOpt.ServiceName("poster"), SignOpt.Secret(secret)})
+	ctx := options.sign(t.Context(), grpc_health_v1.Health_Check_FullMethodName)
+	header := map[string]string{}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		header[key] = values[0]
+	}
+	return header
+}
+
+func TestInvokeHTTP(t *testing.T) {
+	// 请求直接发给ServerApp.NewGateway 不额外添加签名header
+	engine, _ := newTestGateway(t)
+	ts := httptest.NewServer(engine)
+	defer ts.Close()
+	client := httpz.NewDefaultClient()
+	url := ts.URL + "/api/grpc.health.v1.Health/Check"
+	out := new(grpc_health_v1.HealthCheckResponse)
+	if err := InvokeHTTP(t.Context(), client, url, &grpc_health_v1.HealthCheckRequest{Service: "ok"}, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("status=%v", out.Status)
+	}
+	err := InvokeHTTP(t.Context(), client, url, &grpc_health_v1.HealthCheckRequest{Service: "errorz"}, out)
+	if errorz.CodeOf(err) != 10001 || errorz.AlertOf(err) != "订单不存在" {
+		t.Fatalf("err=%v", err)
+	}
+	err = InvokeHTTP(t.Context(), client, ts.URL+"/missing", &grpc_health_v1.HealthCheckRequest{}, out)
+	var statusErr *httpz.StatusError
+	if errorz.CodeOf(err) != httpz.CodeUpstreamErr || !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestGatewayHandle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewGateway().Handle(engine.Group("api/"), &grpc_health_v1.Health_ServiceDesc, &healthServer{})
+	w, result := gatewayPost(t, engine, `{"service":"ok"}`, nil)
+	if w.Code != http.StatusOK || result.Code != 0 {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestGatewayStatusProtobuf(t *testing.T) {
+	engine, _ := newTestGateway(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/grpc.health.v1.Health/Check", strings.NewReader(`{"service":"denied"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", web.MIMEProtobuf)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != web.MIMEProtobuf {
+		t.Fatalf("status=%d content-type=%s", w.Code, w.Header().Get("Content-Type"))
+	}
+	result := new(web.ProtoResult)
+	if err := proto.Unmarshal(w.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != -1 || result.Msg != "denied" {
+		t.Fatalf("result=%v", result)
+	}
+}
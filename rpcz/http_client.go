@@ -0,0 +1,62 @@
+package rpcz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/web"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 与Gateway解码一致 响应中未知字段忽略 便于服务端先加字段
+var httpClientUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+var httpClientMarshal = protojson.MarshalOptions{UseProtoNames: true}
+
+/*
+*
+InvokeHTTP 以V2请求格式调用Gateway挂载的方法 {"version":"V2","params":in}
+响应为web.Result content按protojson解析到out
+protoc-gen-xb生成的XxxHttpClient使用 请求不签名 ServerApp.NewGateway的拦截器链不校验内部签名
+err
+
+	请求失败 errorz.FromStd 包装的原始错误
+	code!=0 errorz.Error code和alert为响应中的code和alert
+	非2xx且不是web.Result errorz.Error code为httpz.CodeUpstreamErr errors.As 可获取 *httpz.StatusError
+*/
+func InvokeHTTP(ctx context.Context, client *httpz.Client, url string, in, out proto.Message) error {
+	params, err := httpClientMarshal.Marshal(in)
+	if err != nil {
+		return errorz.FromStd(err)
+	}
+	body, err := json.Marshal(web.Request{Version: "V2", Params: json.RawMessage(params)})
+	if err != nil {
+		return errorz.FromStd(err)
+	}
+	httpResp, err := client.Post(ctx, url, "application/json", bytes.NewReader(body), http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return errorz.FromStd(err)
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+	respBytes, err := io.ReadAll(io.LimitReader(httpResp.Body, httpz.DefaultMaxResponseBody))
+	if err != nil {
+		return errorz.FromStd(err)
+	}
+	content, err := httpz.ResultContent(httpResp.StatusCode, respBytes, http.MethodPost, url)
+	if err != nil || content == nil {
+		return err
+	}
+	if err = httpClientUnmarshal.Unmarshal(content, out); err != nil {
+		return errorz.Wrap(err, -1, fmt.Sprintf("POST %s unmarshal content err", url))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package rpcz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/songlma/gobase/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 日志中params和result最大长度
+const maxLogBodySize = 1024
+
+// LogUnaryClientInterceptor 记录grpc调用日志 type为grpc_outgoing
+func LogUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		fields := logger.Fields{
+			"type":   "grpc_outgoing",
+			"ts":     float64(time.Since(start).Nanoseconds()) / 1000000,
+			"target": cc.Target(),
+			"method": method,
+			"params": logBody(req),
+		}
+		logResult(ctx, fields, reply, err)
+		return err
+	}
+}
+
+// LogStreamClientInterceptor 记录grpc流建立日志 不记录消息内容
+func LogStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		fields := logger.Fields{
+			"type":   "grpc_outgoing",
+			"ts":     float64(time.Since(start).Nanoseconds()) / 1000000,
+			"target": cc.Target(),
+			"method": method,
+			"stream": true,
+		}
+		logResult(ctx, fields, nil, err)
+		return stream, err
+	}
+}
+
+// LogUnaryServerInterceptor 记录grpc请求日志 type为grpc_income
+func LogUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		fields := logger.Fields{
+			"type":   "grpc_income",
+			"ts":     float64(time.Since(start).Nanoseconds()) / 1000000,
+			"method": info.FullMethod,
+			"params": logBody(req),
+		}
+		logResult(ctx, fields, resp, err)
+		return resp, err
+	}
+}
+
+// LogStreamServerInterceptor 记录grpc流请求日志 流结束时记录 不记录消息内容
+func LogStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		fields := logger.Fields{
+			"type":   "grpc_income",
+			"ts":     float64(time.Since(start).Nanoseconds()) / 1000000,
+			"method": info.FullMethod,
+			"stream": true,
+		}
+		logResult(stream.Context(), fields, nil, err)
+		return err
+	}
+}
+
+func logResult(ctx context.Context, fields logger.Fields, result interface{}, err error) {
+	code := status.Code(err)
+	fields["code"] = code.String()
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.WithFields(ctx, fields).Warnf("%s|%s", fields["method"], code)
+		return
+	}
+	if result != nil {
+		fields["result"] = logBody(result)
+	}
+	logger.WithFields(ctx, fields).Infof("%s|%s", fields["method"], code)
+}
+
+func logBody(v interface{}) string {
+	var body string
+	if message, ok := v.(proto.Message); ok {
+		b, err := protojson.Marshal(message)
+		if err != nil {
+			return err.Error()
+		}
+		body = string(b)
+	} else {
+		body = fmt.Sprintf("%v", v)
+	}
+	if len(body) > maxLogBodySize {
+		body = body[:maxLogBodySize] + "..."
+	}
+	return body
+}
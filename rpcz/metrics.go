@@ -0,0 +1,28 @@
+package rpcz
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	connStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grpc_client_connection_state",
+		Help: "gRPC client connection state: 0 idle, 1 connecting, 2 ready, 3 transient failure, 4 shutdown.",
+	}, []string{"target"})
+
+	connStateTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_connection_state_transitions_total",
+		Help: "Total number of gRPC client connection state transitions.",
+	}, []string{"target", "state"})
+)
+
+// registerMetrics 第一次监听连接状态时注册 未使用ClientPool的服务不导出连接指标
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(connStateGauge, connStateTransitions)
+	})
+}
@@ -0,0 +1,78 @@
+package rpcz
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/songlma/gobase/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PanicUnaryClientInterceptor 恢复客户端拦截器链中的panic 返回codes.Internal
+func PanicUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(ctx, method, r)
+			}
+		}()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// PanicStreamClientInterceptor 恢复建立流时的panic 返回codes.Internal
+func PanicStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (stream grpc.ClientStream, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				stream, err = nil, recoverPanic(ctx, method, r)
+			}
+		}()
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// PanicUnaryServerInterceptor 恢复handler中的panic 返回codes.Internal 避免进程退出
+func PanicUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, recoverPanic(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// PanicStreamServerInterceptor 恢复流handler中的panic 返回codes.Internal
+func PanicStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(stream.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, stream)
+	}
+}
+
+func recoverPanic(ctx context.Context, method string, r interface{}) error {
+	const size = 64 << 10
+	buf := make([]byte, size)
+	buf = buf[:runtime.Stack(buf, false)]
+	logger.WithFields(ctx, logger.Fields{"type": "panic", "method": method}).Errorf("GRPC: panic running job: %v\n%s", r, buf)
+	span, _ := opentracing.StartSpanFromContext(ctx, "grpcPanic")
+	ext.Error.Set(span, true)
+	span.LogKV("event", "error")
+	span.LogKV("error.kind", "grpcPanic")
+	span.LogKV("error.object", "Panic")
+	span.LogKV("message", fmt.Sprintf("%v", r))
+	span.LogKV("stack", string(buf))
+	span.Finish()
+	return status.Errorf(codes.Internal, "panic: %v", r)
+}
@@ -0,0 +1,286 @@
+package rpcz
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/inner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// healthServer 按service返回不同结果
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	mu          sync.Mutex
+	serviceName string
+	md          metadata.MD
+}
+
+func (server *healthServer) handle(ctx context.Context, service string) error {
+	server.mu.Lock()
+	server.serviceName = inner.GetRequestServiceName(ctx)
+	server.md, _ = metadata.FromIncomingContext(ctx)
+	server.mu.Unlock()
+	switch service {
+	case "errorz":
+		return errorz.New(10001, "order not found", errorz.WithAlert("订单不存在"))
+	case "panic":
+		panic("boom")
+	case "denied":
+		return status.Error(codes.PermissionDenied, "denied")
+	}
+	return nil
+}
+
+func (server *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if err := server.handle(ctx, req.Service); err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (server *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	return server.handle(stream.Context(), req.Service)
+}
+
+func (server *healthServer) incomingMD() metadata.MD {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.md
+}
+
+func (server *healthServer) requestServiceName() string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.serviceName
+}
+
+type testEnv struct {
+	server       *healthServer
+	serverTracer *mocktracer.MockTracer
+	clientTracer *mocktracer.MockTracer
+	client       grpc_health_v1.HealthClient
+}
+
+func newTestEnv(t *testing.T, secret string) *testEnv {
+	env := &testEnv{
+		server:       &healthServer{},
+		serverTracer: mocktracer.New(),
+		clientTracer: mocktracer.New(),
+	}
+	signConfig := &httpz.SignConfig{Secrets: map[string]string{"poster": "secret"}}
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(
+		WithUnaryServerChain(
+			OpenTracingUnaryServerInterceptor(env.serverTracer),
+			SignUnaryServerInterceptor(signConfig),
+			LogUnaryServerInterceptor(),
+			ErrorzUnaryServerInterceptor(),
+			PanicUnaryServerInterceptor(),
+		),
+		WithStreamServerChain(
+			OpenTracingStreamServerInterceptor(env.serverTracer),
+			SignStreamServerInterceptor(signConfig),
+			LogStreamServerInterceptor(),
+			ErrorzStreamServerInterceptor(),
+			PanicStreamServerInterceptor(),
+		),
+	)
+	grpc_health_v1.RegisterHealthServer(grpcServer, env.server)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		WithUnaryClientInterceptor(
+			OpenTracingUnaryClientInterceptor(env.clientTracer),
+			SignUnaryClientInterceptor(SignOpt.ServiceName("poster"), SignOpt.Secret(secret)),
+			LogUnaryClientInterceptor(),
+			ErrorzUnaryClientInterceptor(),
+			PanicUnaryClientInterceptor(),
+		),
+		WithStreamClientInterceptor(
+			OpenTracingStreamClientInterceptor(env.clientTracer),
+			SignStreamClientInterceptor(SignOpt.ServiceName("poster"), SignOpt.Secret(secret)),
+			LogStreamClientInterceptor(),
+			ErrorzStreamClientInterceptor(),
+			PanicStreamClientInterceptor(),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		grpcServer.Stop()
+	})
+	env.client = grpc_health_v1.NewHealthClient(conn)
+	return env
+}
+
+func TestUnaryChain(t *testing.T) {
+	env := newTestEnv(t, "secret")
+	resp, err := env.client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("status=%v", resp.Status)
+	}
+	if name := env.server.requestServiceName(); name != "poster" {
+		t.Fatalf("service name=%q", name)
+	}
+	clientSpans := env.clientTracer.FinishedSpans()
+	serverSpans := env.serverTracer.FinishedSpans()
+	if len(clientSpans) != 1 || len(serverSpans) != 1 {
+		t.Fatalf("client spans=%d server spans=%d", len(clientSpans), len(serverSpans))
+	}
+	if serverSpans[0].ParentID != clientSpans[0].SpanContext.SpanID {
+		t.Fatalf("server parent=%d client span=%d", serverSpans[0].ParentID, clientSpans[0].SpanContext.SpanID)
+	}
+	if serverSpans[0].SpanContext.TraceID != clientSpans[0].SpanContext.TraceID {
+		t.Fatal("trace id not propagated")
+	}
+	if clientSpans[0].OperationName != "gRPC Client "+grpc_health_v1.Health_Check_FullMethodName {
+		t.Fatalf("operation=%s", clientSpans[0].OperationName)
+	}
+}
+
+func TestUnaryErrorz(t *testing.T) {
+	env := newTestEnv(t, "secret")
+	_, err := env.client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "errorz"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if code := errorz.CodeOf(err); code != 10001 {
+		t.Fatalf("code=%d err=%v", code, err)
+	}
+	if alert := errorz.AlertOf(err); alert != "订单不存在" {
+		t.Fatalf("alert=%q", alert)
+	}
+	if msg := errorz.MsgOf(err); msg != "order not found" {
+		t.Fatalf("msg=%q", msg)
+	}
+	if code := status.Code(err); code != codes.Unknown {
+		t.Fatalf("grpc code=%v", code)
+	}
+	spans := env.clientTracer.FinishedSpans()
+	if len(spans) != 1 || spans[0].Tag("error") != true {
+		t.Fatal("client span should be marked error")
+	}
+}
+
+func TestUnaryPanic(t *testing.T) {
+	env := newTestEnv(t, "secret")
+	_, err := env.client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "panic"})
+	if code := status.Code(err); code != codes.Internal {
+		t.Fatalf("code=%v err=%v", code, err)
+	}
+	if code := errorz.CodeOf(err); code != -1 {
+		t.Fatalf("errorz code=%d", code)
+	}
+}
+
+func TestUnarySignRejected(t *testing.T) {
+	env := newTestEnv(t, "wrong")
+	_, err := env.client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "ok"})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("code=%v err=%v", code, err)
+	}
+	if name := env.server.requestServiceName(); name != "" {
+		t.Fatal("handler should not run")
+	}
+}
+
+func TestStreamChain(t *testing.T) {
+	env := newTestEnv(t, "secret")
+	tests := []struct {
+		service string
+		code    codes.Code
+	}{
+		{service: "ok", code: codes.OK},
+		{service: "errorz", code: codes.Unknown},
+		{service: "panic", code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			stream, err := env.client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: tt.service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+				t.Fatalf("status=%v", resp.Status)
+			}
+			_, err = stream.Recv()
+			if tt.code == codes.OK {
+				if err == nil || err.Error() != "EOF" {
+					t.Fatalf("expected EOF got %v", err)
+				}
+			} else if code := status.Code(err); code != tt.code {
+				t.Fatalf("code=%v err=%v", code, err)
+			}
+			if tt.service == "errorz" && errorz.AlertOf(err) != "订单不存在" {
+				t.Fatalf("alert=%q", errorz.AlertOf(err))
+			}
+			if name := env.server.requestServiceName(); name != "poster" {
+				t.Fatalf("service name=%q", name)
+			}
+		})
+	}
+	if spans := env.clientTracer.FinishedSpans(); len(spans) != len(tests) {
+		t.Fatalf("client spans=%d", len(spans))
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	if ToStatus(nil) != nil {
+		t.Fatal("nil error should be nil status")
+	}
+	st := ToStatus(status.Error(codes.NotFound, "missing"))
+	if st.Code() != codes.NotFound {
+		t.Fatalf("code=%v", st.Code())
+	}
+	st = ToStatus(context.DeadlineExceeded)
+	if st.Code() != codes.DeadlineExceeded {
+		t.Fatalf("code=%v", st.Code())
+	}
+	err := FromStatusError(ToStatus(errorz.New(20002, "bad", errorz.WithAlert("错误"))).Err())
+	if errorz.CodeOf(err) != 20002 || errorz.AlertOf(err) != "错误" || status.Code(err) != codes.Unknown {
+		t.Fatalf("round trip err=%v", err)
+	}
+	//注册了http状态码的业务码 使用对应的grpc code
+	errorz.Register(20003, http.StatusNotFound, "不存在")
+	if st = ToStatus(errorz.New(20003, "missing")); st.Code() != codes.NotFound {
+		t.Fatalf("registered code=%v", st.Code())
+	}
+	//业务码0不能变成codes.OK
+	st = ToStatus(errorz.New(0, "zero"))
+	if err = st.Err(); err == nil || st.Code() != codes.Unknown {
+		t.Fatalf("zero code=%v", st.Code())
+	}
+	if err = FromStatusError(err); errorz.CodeOf(err) != 0 || errorz.MsgOf(err) != "zero" {
+		t.Fatalf("zero round trip err=%v", err)
+	}
+}
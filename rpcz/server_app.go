@@ -0,0 +1,223 @@
+package rpcz
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+)
+
+// ServerConfig grpc服务配置
+type ServerConfig struct {
+	Addr string //监听地址 例如 :8081
+	//GracefulStop最长等待时间 超时后强制Stop 默认10秒 Stop的ctx先到期时以ctx为准
+	ShutdownTimeout time.Duration
+	Tracer          opentracing.Tracer //为nil时使用opentracing.GlobalTracer()
+	SignConfig      *httpz.SignConfig  //为nil时不校验签名 只读取调用方服务名
+	//Ready 就绪检查 健康检查服务和ServerApp.Ready使用 为nil时启动后即就绪
+	Ready func(ctx context.Context) bool
+	//追加在标准拦截器链之后
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	ServerOptions      []grpc.ServerOption
+	DisableReflection  bool //关闭服务反射 默认开启 便于grpcurl调试
+}
+
+// NewServerConfig 默认配置
+func NewServerConfig(addr string) ServerConfig {
+	return ServerConfig{
+		Addr:            addr,
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+/*
+*
+ServerApp grpc服务 实现app.App
+标准拦截器链 Panic -> OpenTracing -> Sign -> Log -> Errorz
+Panic在最外层 其余拦截器(包括自定义拦截器)中的panic同样会被恢复
+NewGateway的拦截器链不包含Sign 请求来自外部http 鉴权由http中间件完成
+注册health服务(空服务名的状态由Ready决定)和reflection服务
+示例:
+
+	rpcApp := rpcz.NewServerApp(ctx, rpcz.NewServerConfig(config.GetString("config.api.grpc_port")))
+	model.RegisterPosterInnerServer(rpcApp.Server(), &inner.PosterInnerServer{})
+	go rpcApp.Start(ctx)
+*/
+type ServerApp struct {
+	conf   ServerConfig
+	server *grpc.Server
+	health *health.Server
+	//Gateway使用的拦截器链
+	gatewayUnary []grpc.UnaryServerInterceptor
+
+	mu       sync.Mutex
+	listener net.Listener
+	serving  bool
+	stopped  bool
+}
+
+func NewServerApp(ctx context.Context, conf ServerConfig) *ServerApp {
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = 10 * time.Second
+	}
+	tracer := conf.Tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+	unary := append([]grpc.UnaryServerInterceptor{
+		PanicUnaryServerInterceptor(),
+		OpenTracingUnaryServerInterceptor(tracer),
+		SignUnaryServerInterceptor(conf.SignConfig),
+		LogUnaryServerInterceptor(),
+		ErrorzUnaryServerInterceptor(),
+	}, conf.UnaryInterceptors...)
+	gatewayUnary := append([]grpc.UnaryServerInterceptor{
+		PanicUnaryServerInterceptor(),
+		OpenTracingUnaryServerInterceptor(tracer),
+		LogUnaryServerInterceptor(),
+		ErrorzUnaryServerInterceptor(),
+	}, conf.UnaryInterceptors...)
+	stream := append([]grpc.StreamServerInterceptor{
+		PanicStreamServerInterceptor(),
+		OpenTracingStreamServerInterceptor(tracer),
+		SignStreamServerInterceptor(conf.SignConfig),
+		LogStreamServerInterceptor(),
+		ErrorzStreamServerInterceptor(),
+	}, conf.StreamInterceptors...)
+	opts := append([]grpc.ServerOption{
+		WithUnaryServerChain(unary...),
+		WithStreamServerChain(stream...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: 5 * time.Minute}),
+		//允许ClientPool默认的keepalive频率 默认策略5分钟内多次ping会被断开
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}),
+	}, conf.ServerOptions...)
+	app := &ServerApp{
+		conf:         conf,
+		server:       grpc.NewServer(opts...),
+		health:       health.NewServer(),
+		gatewayUnary: gatewayUnary,
+	}
+	app.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(app.server, &readyHealthServer{Server: app.health, app: app})
+	if !conf.DisableReflection {
+		reflection.Register(app.server)
+	}
+	return app
+}
+
+// Server 用于注册业务服务 需在Start之前调用
+func (app *ServerApp) Server() *grpc.Server {
+	return app.server
+}
+
+// Addr 实际监听地址 未启动时返回nil
+func (app *ServerApp) Addr() net.Addr {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.listener == nil {
+		return nil
+	}
+	return app.listener.Addr()
+}
+
+func (app *ServerApp) Name() string {
+	return "grpc server@" + app.conf.Addr
+}
+
+func (app *ServerApp) Once(ctx context.Context, params string) errorz.Error {
+	return nil
+}
+
+// Start 监听并阻塞直到Stop
+func (app *ServerApp) Start(ctx context.Context) errorz.Error {
+	app.mu.Lock()
+	if app.stopped {
+		app.mu.Unlock()
+		return nil
+	}
+	lis, err := net.Listen("tcp", app.conf.Addr)
+	if err != nil {
+		app.mu.Unlock()
+		logger.Error(ctx, fmt.Sprintf("ServerApp Listen %s err:%v", app.conf.Addr, err))
+		return errorz.FromStd(err)
+	}
+	app.listener = lis
+	app.serving = true
+	app.mu.Unlock()
+	app.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	logger.Info(ctx, fmt.Sprintf("start grpc app at %s", lis.Addr()))
+	if err = app.server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		logger.Error(ctx, fmt.Sprintf("ServerApp %s Serve err:%v", app.conf.Addr, err))
+		return errorz.FromStd(err)
+	}
+	return nil
+}
+
+/*
+*
+Stop 健康检查先置为NOT_SERVING 然后GracefulStop
+超过ShutdownTimeout或ctx到期后强制Stop 断开未完成的请求
+*/
+func (app *ServerApp) Stop(ctx context.Context) errorz.Error {
+	app.mu.Lock()
+	app.stopped = true
+	app.serving = false
+	app.mu.Unlock()
+	app.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		app.server.GracefulStop()
+		close(done)
+	}()
+	timer := time.NewTimer(app.conf.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	logger.Warn(ctx, "ServerApp GracefulStop timeout, force stop")
+	app.server.Stop()
+	<-done
+	return nil
+}
+
+// Ready 已启动且ServerConfig.Ready返回true
+func (app *ServerApp) Ready(ctx context.Context) bool {
+	app.mu.Lock()
+	serving := app.serving
+	app.mu.Unlock()
+	if !serving {
+		return false
+	}
+	return app.conf.Ready == nil || app.conf.Ready(ctx)
+}
+
+// readyHealthServer 空服务名的Check由ServerApp.Ready决定 其他服务使用health.Server中设置的状态
+type readyHealthServer struct {
+	*health.Server
+	app *ServerApp
+}
+
+func (server *readyHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if req.Service != "" {
+		return server.Server.Check(ctx, req)
+	}
+	if server.app.Ready(ctx) {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+}
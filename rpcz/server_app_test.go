@@ -0,0 +1,159 @@
+package rpcz
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// panicTracer StartSpan时panic 用于测试拦截器中的panic
+type panicTracer struct {
+	*mocktracer.MockTracer
+}
+
+func (tracer panicTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	panic("tracer boom")
+}
+
+// blockingServer Watch一直阻塞 用于测试强制Stop
+type blockingServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	started chan struct{}
+}
+
+func (server *blockingServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	close(server.started)
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func startServerApp(t *testing.T, conf ServerConfig) (*ServerApp, *grpc.ClientConn) {
+	ctx := context.Background()
+	rpcApp := NewServerApp(ctx, conf)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := rpcApp.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	for i := 0; rpcApp.Addr() == nil; i++ {
+		if i > 100 {
+			t.Fatal("server not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn, err := grpc.NewClient(rpcApp.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = rpcApp.Stop(ctx)
+		<-done
+	})
+	return rpcApp, conn
+}
+
+func TestServerAppHealth(t *testing.T) {
+	var ready atomic.Bool
+	ready.Store(true)
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = mocktracer.New()
+	conf.Ready = func(ctx context.Context) bool {
+		return ready.Load()
+	}
+	rpcApp, conn := startServerApp(t, conf)
+	client := grpc_health_v1.NewHealthClient(conn)
+	ctx := context.Background()
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("status=%v", resp.Status)
+	}
+	ready.Store(false)
+	resp, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status=%v", resp.Status)
+	}
+	if rpcApp.Ready(ctx) {
+		t.Fatal("app should not be ready")
+	}
+	if _, ok := rpcApp.Server().GetServiceInfo()["grpc.reflection.v1.ServerReflection"]; !ok {
+		t.Fatal("reflection not registered")
+	}
+}
+
+func TestServerAppStopTimeout(t *testing.T) {
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = mocktracer.New()
+	conf.ShutdownTimeout = 100 * time.Millisecond
+	conf.DisableReflection = true
+	server := &blockingServer{started: make(chan struct{})}
+	ctx := context.Background()
+	rpcApp := NewServerApp(ctx, conf)
+	// 自定义服务与内置health服务同名 使用独立的ServiceDesc注册
+	desc := grpc_health_v1.Health_ServiceDesc
+	desc.ServiceName = "test.Blocking"
+	rpcApp.Server().RegisterService(&desc, server)
+	go func() {
+		_ = rpcApp.Start(ctx)
+	}()
+	for rpcApp.Addr() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn, err := grpc.NewClient(rpcApp.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := conn.NewStream(ctx, &desc.Streams[0], "/test.Blocking/Watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.SendMsg(&grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.CloseSend()
+	<-server.started
+	start := time.Now()
+	_ = rpcApp.Stop(ctx)
+	if cost := time.Since(start); cost > 2*time.Second {
+		t.Fatalf("stop took %v", cost)
+	}
+	if rpcApp.Ready(ctx) {
+		t.Fatal("app should not be ready after stop")
+	}
+}
+
+func TestServerAppInterceptorPanic(t *testing.T) {
+	conf := NewServerConfig("127.0.0.1:0")
+	conf.Tracer = panicTracer{MockTracer: mocktracer.New()}
+	_, conn := startServerApp(t, conf)
+	ctx := context.Background()
+	_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("unary err=%v", err)
+	}
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); status.Code(err) != codes.Internal {
+		t.Fatalf("stream err=%v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package rpcz
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/inner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadata key grpc要求小写
+const (
+	signKey        = "sign"
+	timestampKey   = "timestamp"
+	nonceKey       = "nonce"
+	serviceNameKey = "sign_service_name"
+)
+
+type signOptions struct {
+	serviceName string
+	secret      string
+}
+
+// SignOption 签名可选项
+type SignOption func(*signOptions)
+
+type signOpt struct{}
+
+// SignOpt 签名可选项 例如 rpcz.SignOpt.ServiceName("poster")
+var SignOpt signOpt
+
+// ServiceName 调用方服务名
+func (signOpt) ServiceName(serviceName string) SignOption {
+	return func(options *signOptions) {
+		options.serviceName = serviceName
+	}
+}
+
+// Secret 签名密钥 为空时只传递服务名
+func (signOpt) Secret(secret string) SignOption {
+	return func(options *signOptions) {
+		options.secret = secret
+	}
+}
+
+/*
+*
+SignUnaryClientInterceptor 在metadata中添加调用方服务名和签名
+签名规则同 httpz.Sign method为POST path为grpc方法全名 body为空
+*/
+func SignUnaryClientInterceptor(opts ...SignOption) grpc.UnaryClientInterceptor {
+	options := newSignOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		return invoker(options.sign(ctx, method), method, req, reply, cc, callOpts...)
+	}
+}
+
+// SignStreamClientInterceptor 在metadata中添加调用方服务名和签名
+func SignStreamClientInterceptor(opts ...SignOption) grpc.StreamClientInterceptor {
+	options := newSignOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(options.sign(ctx, method), desc, cc, method, callOpts...)
+	}
+}
+
+func newSignOptions(opts []SignOption) *signOptions {
+	options := &signOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func (options *signOptions) sign(ctx context.Context, method string) context.Context {
+	pairs := []string{serviceNameKey, options.serviceName}
+	if options.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		nonce := hex.EncodeToString(buf)
+		pairs = append(pairs,
+			timestampKey, timestamp,
+			nonceKey, nonce,
+			signKey, httpz.Sign(options.secret, http.MethodPost, method, timestamp, nonce, options.serviceName, nil),
+		)
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+/*
+*
+SignUnaryServerInterceptor 校验调用方签名 并将服务名放入ctx 通过inner.GetRequestServiceName获取
+signConfig为nil时不校验签名 只读取服务名
+校验失败返回 codes.Unauthenticated
+*/
+func SignUnaryServerInterceptor(signConfig *httpz.SignConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := verifySign(ctx, signConfig, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// SignStreamServerInterceptor 校验调用方签名 并将服务名放入ctx
+func SignStreamServerInterceptor(signConfig *httpz.SignConfig) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := verifySign(stream.Context(), signConfig, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, wrapServerStream(stream, ctx))
+	}
+}
+
+func verifySign(ctx context.Context, signConfig *httpz.SignConfig, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	serviceName := firstValue(md, serviceNameKey)
+	if signConfig == nil {
+		return inner.WithRequestServiceName(ctx, serviceName), nil
+	}
+	secret, ok := signConfig.Secrets[serviceName]
+	signValue := firstValue(md, signKey)
+	timestamp := firstValue(md, timestampKey)
+	nonce := firstValue(md, nonceKey)
+	if !ok || secret == "" || signValue == "" || timestamp == "" || nonce == "" {
+		return ctx, status.Error(codes.Unauthenticated, "bad request sign")
+	}
+	requestTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, "bad request timestamp")
+	}
+	skew := time.Since(time.Unix(requestTime, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	maxSkew := signConfig.Skew
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	if skew > maxSkew {
+		return ctx, status.Error(codes.Unauthenticated, "bad request timestamp expired")
+	}
+	expected := httpz.Sign(secret, http.MethodPost, method, timestamp, nonce, serviceName, nil)
+	if !hmac.Equal([]byte(expected), []byte(signValue)) {
+		return ctx, status.Error(codes.Unauthenticated, "bad request sign")
+	}
+	if signConfig.Nonce != nil {
+		nonceTTL := signConfig.NonceTTL
+		if nonceTTL <= 0 {
+			nonceTTL = 2 * maxSkew
+		}
+		claimed, err := signConfig.Nonce.Claim(ctx, serviceName+":"+nonce, nonceTTL)
+		if err != nil {
+			return ctx, status.Error(codes.Unavailable, "nonce store unavailable")
+		}
+		if !claimed {
+			return ctx, status.Error(codes.Unauthenticated, "bad request nonce replayed")
+		}
+	}
+	return inner.WithRequestServiceName(ctx, serviceName), nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
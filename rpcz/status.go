@@ -0,0 +1,190 @@
+package rpcz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/songlma/gobase/errorz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// status details中保存errorz信息的字段
+const (
+	detailErrorzCode = "errorz_code"
+	detailAlert      = "alert"
+)
+
+/*
+*
+ToStatus 将错误转为grpc status
+errorz错误(业务码不为-1) message为errorz.MsgOf details中携带业务码和alert
+code为errorz.HTTPStatusOf对应的grpc code(见CodeFromHTTPStatus) 业务码未注册时为codes.Unknown
+已是grpc status的错误原样返回 其他错误为codes.Unknown
+err为nil时返回nil
+*/
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	code := errorz.CodeOf(err)
+	if code == -1 {
+		if st, ok := status.FromError(err); ok {
+			return st
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err)
+		}
+		return status.New(codes.Unknown, err.Error())
+	}
+	st := status.New(CodeFromHTTPStatus(errorz.HTTPStatusOf(err)), errorz.MsgOf(err))
+	detail, detailErr := structpb.NewStruct(map[string]interface{}{
+		detailErrorzCode: code,
+		detailAlert:      errorz.AlertOf(err),
+	})
+	if detailErr != nil {
+		return st
+	}
+	if withDetails, detailErr := st.WithDetails(detail); detailErr == nil {
+		return withDetails
+	}
+	return st
+}
+
+// CodeFromHTTPStatus http状态码对应的grpc code 与HTTPStatusFromCode相反 无对应时为codes.Unknown
+func CodeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+/*
+*
+FromStatusError 将ToStatus生成的grpc错误还原为errorz错误
+可通过errorz.CodeOf errorz.AlertOf获取业务码和提示 status.Code(err)不变
+不含errorz信息的错误原样返回
+*/
+func FromStatusError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+	for _, detail := range st.Details() {
+		fields, ok := detail.(*structpb.Struct)
+		if !ok {
+			continue
+		}
+		codeValue, ok := fields.GetFields()[detailErrorzCode]
+		if !ok {
+			continue
+		}
+		alert := fields.GetFields()[detailAlert].GetStringValue()
+		return &StatusError{
+			err: errorz.Wrap(err, int(codeValue.GetNumberValue()), st.Message(), errorz.WithAlert(alert)),
+			st:  st,
+		}
+	}
+	return err
+}
+
+// StatusError FromStatusError还原的errorz错误 GRPCStatus返回原始status 业务码不会被当作grpc code
+type StatusError struct {
+	err errorz.Error
+	st  *status.Status
+}
+
+func (e *StatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+func (e *StatusError) Cause() error {
+	return e.err.Cause()
+}
+
+func (e *StatusError) Code() int {
+	return e.err.Code()
+}
+
+func (e *StatusError) Alert() string {
+	return e.err.Alert()
+}
+
+func (e *StatusError) GRPCStatus() *status.Status {
+	return e.st
+}
+
+// ErrorzUnaryServerInterceptor 将handler返回的错误通过ToStatus转为grpc status
+func ErrorzUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, ToStatus(err).Err()
+		}
+		return resp, nil
+	}
+}
+
+// ErrorzStreamServerInterceptor 将流handler返回的错误通过ToStatus转为grpc status
+func ErrorzStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, stream); err != nil {
+			return ToStatus(err).Err()
+		}
+		return nil
+	}
+}
+
+// ErrorzUnaryClientInterceptor 通过FromStatusError将服务端返回的错误还原为errorz错误
+func ErrorzUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return FromStatusError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// ErrorzStreamClientInterceptor 通过FromStatusError还原建立流和RecvMsg返回的错误
+func ErrorzStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, FromStatusError(err)
+		}
+		return &errorzClientStream{ClientStream: stream}, nil
+	}
+}
+
+type errorzClientStream struct {
+	grpc.ClientStream
+}
+
+func (stream *errorzClientStream) RecvMsg(m interface{}) error {
+	return FromStatusError(stream.ClientStream.RecvMsg(m))
+}
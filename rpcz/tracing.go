@@ -0,0 +1,33 @@
+package rpcz
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/trace"
+	"google.golang.org/grpc"
+)
+
+/*
+*
+OpenTracing拦截器由trace包实现 span context通过metadata传递
+同时读写旧版TraceId ParentSpanId 与未升级的服务互通
+*/
+
+// OpenTracingUnaryClientInterceptor 创建客户端span 并通过metadata传递span context
+func OpenTracingUnaryClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
+	return trace.OpenTracingUnaryClientInterceptor(tracer)
+}
+
+// OpenTracingStreamClientInterceptor 创建客户端span 流结束(RecvMsg返回错误或io.EOF)时finish
+func OpenTracingStreamClientInterceptor(tracer opentracing.Tracer) grpc.StreamClientInterceptor {
+	return trace.OpenTracingStreamClientInterceptor(tracer)
+}
+
+// OpenTracingUnaryServerInterceptor 从metadata中提取span context 创建服务端span
+func OpenTracingUnaryServerInterceptor(tracer opentracing.Tracer) grpc.UnaryServerInterceptor {
+	return trace.OpenTracingUnaryServerInterceptor(tracer)
+}
+
+// OpenTracingStreamServerInterceptor 从metadata中提取span context 创建服务端span
+func OpenTracingStreamServerInterceptor(tracer opentracing.Tracer) grpc.StreamServerInterceptor {
+	return trace.OpenTracingStreamServerInterceptor(tracer)
+}
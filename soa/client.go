@@ -0,0 +1,103 @@
+package soa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/web"
+)
+
+// Version 内部服务请求协议版本
+const Version = "V2"
+
+type Config struct {
+	Addr        string        //被调用服务地址 例如 http://poster.default.svc:8080
+	Host        string        //请求Host 为空时使用Addr中的host
+	ServiceName string        //当前服务名 通过Service-Name header传递
+	Secret      string        //签名密钥 为空时不做HMAC签名
+	Timeout     time.Duration //请求超时 默认10s
+}
+
+type Client struct {
+	conf   Config
+	client *httpz.Client
+}
+
+func NewClient(conf Config) *Client {
+	if conf.Timeout == 0 {
+		conf.Timeout = 10 * time.Second
+	}
+	client := httpz.NewDefaultClient()
+	client.WithTimeout(conf.Timeout)
+	if conf.Secret != "" {
+		client.WithSign(conf.ServiceName, conf.Secret)
+	}
+	return &Client{
+		conf:   conf,
+		client: client,
+	}
+}
+
+// NewClientWithHttpClient 自定义httpz.Client 例如设置重试和熔断
+func NewClientWithHttpClient(conf Config, client *httpz.Client) *Client {
+	return &Client{
+		conf:   conf,
+		client: client,
+	}
+}
+
+/*
+*
+RequestV2 请求内部go服务
+method 请求方法名 例如：/inner/poster/detail
+params 参数 以 {"version":"V2","params":params} 格式发送
+result 对应接口返回的content 指针类型 为nil时不解析
+err
+
+	code!=0 时返回errorz.Error 包含返回的code和alert
+*/
+func (soa *Client) RequestV2(ctx context.Context, method string, params interface{}, result interface{}) error {
+	content, err := httpz.PostJSON[web.Request, json.RawMessage](ctx, soa.client, soa.url(method), web.Request{
+		Version: Version,
+		Params:  params,
+	}, httpz.JSONHeader(soa.header()), httpz.JSONResultEnvelope())
+	if err != nil {
+		return err
+	}
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(content, result); err != nil {
+		return errorz.Wrap(err, -1, "soa RequestV2 unmarshal content err method:"+method)
+	}
+	return nil
+}
+
+// Call 泛型版本的RequestV2
+func Call[Req, Resp any](ctx context.Context, soa *Client, method string, params Req) (Resp, error) {
+	return httpz.PostJSON[web.Request, Resp](ctx, soa.client, soa.url(method), web.Request{
+		Version: Version,
+		Params:  params,
+	}, httpz.JSONHeader(soa.header()), httpz.JSONResultEnvelope())
+}
+
+func (soa *Client) url(method string) string {
+	return strings.TrimRight(soa.conf.Addr, "/") + "/" + strings.TrimLeft(method, "/")
+}
+
+// header 内部请求header Trace-ID CorralId 以及mesh header 由httpz.Client自动传递
+func (soa *Client) header() http.Header {
+	header := http.Header{}
+	if soa.conf.Host != "" {
+		header.Set("Host", soa.conf.Host)
+	}
+	if soa.conf.ServiceName != "" {
+		header.Set(httpz.ServiceNameKey, soa.conf.ServiceName)
+	}
+	return header
+}
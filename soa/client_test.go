@@ -0,0 +1,65 @@
+package soa
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/contextz"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/httpz"
+	"github.com/songlma/gobase/web"
+)
+
+type detailReq struct {
+	Id int64 `json:"id"`
+}
+
+type detailResp struct {
+	Title string `json:"title"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	ginEngine := gin.New()
+	innerGroup := ginEngine.Group("inner/", httpz.InterSignGinHandlerFunc(&httpz.SignConfig{
+		Secrets: map[string]string{"poster": "secret"},
+	}))
+	innerGroup.POST("/detail", func(c *gin.Context) {
+		if c.GetHeader(web.CorralIdKey) != "corral" || c.GetHeader(httpz.TraceIdKey) != "trace" {
+			t.Errorf("header not forwarded %v", c.Request.Header)
+		}
+		var req detailReq
+		if err := httpz.ShouldBindBodyWith(c, &req); err != nil {
+			t.Error(err)
+		}
+		if req.Id != 1 {
+			web.SetApiResultError(c.Request.Context(), c.Writer, errorz.New(404, "not found"), "内容不存在")
+			return
+		}
+		web.SetApiResultSuccess(c.Request.Context(), c.Writer, detailResp{Title: "title"})
+	})
+	return httptest.NewServer(ginEngine)
+}
+
+func TestClient_RequestV2(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	ctx, _ := contextz.SetCorralID(context.Background(), "corral")
+	ctx, _ = contextz.SetTraceID(ctx, "trace")
+
+	client := NewClient(Config{
+		Addr:        server.URL,
+		ServiceName: "poster",
+		Secret:      "secret",
+	})
+	var resp detailResp
+	if err := client.RequestV2(ctx, "/inner/detail", detailReq{Id: 1}, &resp); err != nil || resp.Title != "title" {
+		t.Errorf("want title got %+v %v", resp, err)
+	}
+
+	_, err := Call[detailReq, detailResp](ctx, client, "/inner/detail", detailReq{Id: 2})
+	if errorz.CodeOf(err) != 404 || errorz.AlertOf(err) != "内容不存在" {
+		t.Errorf("want code 404 got %v", err)
+	}
+}
@@ -3,31 +3,43 @@ package trace
 import (
 	"context"
 	"testing"
-	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/songlma/gobase/trace/tracetest"
+	uber "github.com/uber/jaeger-client-go"
 )
 
+// TestJaeger 只初始化tracer 上报为UDP 没有agent时不影响测试
 func TestJaeger(t *testing.T) {
-	var ctx = context.TODO()
+	prev := opentracing.GlobalTracer()
+	defer opentracing.SetGlobalTracer(prev)
 	closer, err := InitJaeger(Config{
-		Service:  "TestJaeger",
-		url:      "http://zipkin.istio-system:9411",
-		hostPort: "9411",
+		Service:            "TestJaeger",
+		LocalAgentHostPort: "127.0.0.1:6831",
+		SamplerType:        "const",
+		SamplerParam:       1,
 	})
 	if err != nil {
-		t.Errorf("Could not initialize jaeger tracer: %s", err.Error())
-		return
+		t.Fatalf("Could not initialize jaeger tracer: %s", err.Error())
 	}
 	defer closer.Close()
+	if _, ok := opentracing.GlobalTracer().(*uber.Tracer); !ok {
+		t.Fatalf("global tracer = %T", opentracing.GlobalTracer())
+	}
+	span, ctx := opentracing.StartSpanFromContext(context.TODO(), "span_1")
+	defer span.Finish()
+	if got := TraceIDFromContext(ctx); got != span.Context().(uber.SpanContext).TraceID().String() {
+		t.Errorf("TraceIDFromContext = %s", got)
+	}
+}
 
-	span1, ctx := opentracing.StartSpanFromContext(ctx, "span_1")
-	time.Sleep(time.Second / 2)
-
+func TestStartSpanFromContext(t *testing.T) {
+	recorder := tracetest.Install(t)
+	span1, ctx := opentracing.StartSpanFromContext(context.TODO(), "span_1")
 	span11, _ := opentracing.StartSpanFromContext(ctx, "span_1-1")
-	time.Sleep(time.Second / 2)
 	span11.Finish()
-
 	span1.Finish()
 
+	recorder.AssertOperationNames(t, "span_1", "span_1-1")
+	recorder.AssertSpan(t, "span_1-1").ChildOf(recorder.AssertSpan(t, "span_1").Root())
 }
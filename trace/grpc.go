@@ -0,0 +1,257 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	uber "github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const grpcComponentName = "gRPC"
+
+// grpc metadata的key为小写
+var (
+	legacyTraceIdMDKey      = strings.ToLower(TraceIdKey)
+	legacyParentSpanIdMDKey = strings.ToLower(ParentSpanIDKey)
+)
+
+// grpcMetadataCarrier opentracing TextMap 读写grpc metadata
+type grpcMetadataCarrier metadata.MD
+
+func (carrier grpcMetadataCarrier) Set(key, val string) {
+	key = strings.ToLower(key)
+	metadata.MD(carrier)[key] = append(metadata.MD(carrier)[key], val)
+}
+
+func (carrier grpcMetadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for key, values := range carrier {
+		//二进制header不是文本
+		if strings.HasSuffix(key, "-bin") {
+			continue
+		}
+		for _, value := range values {
+			if err := handler(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+*
+InjectGrpcMetadata 将span context写入metadata
+同时写入旧版的TraceId ParentSpanId 未接入opentracing的服务仍可读取
+*/
+func InjectGrpcMetadata(tracer opentracing.Tracer, spanContext opentracing.SpanContext, md metadata.MD) error {
+	err := tracer.Inject(spanContext, opentracing.TextMap, grpcMetadataCarrier(md))
+	if traceId := traceIDFromSpanContext(spanContext); traceId != "" {
+		md.Set(legacyTraceIdMDKey, traceId)
+		if spanId := spanIDFromSpanContext(spanContext); spanId != "" {
+			md.Set(legacyParentSpanIdMDKey, spanId)
+		}
+	}
+	return err
+}
+
+/*
+*
+ExtractGrpcMetadata 从metadata中读取span context
+tracer格式中没有时 使用旧版TraceId ParentSpanId 按jaeger(uber-trace-id) B3和W3C traceparent格式再解析一次
+*/
+func ExtractGrpcMetadata(tracer opentracing.Tracer, md metadata.MD) (opentracing.SpanContext, error) {
+	spanContext, err := tracer.Extract(opentracing.TextMap, grpcMetadataCarrier(md))
+	if err == nil && spanContext != nil {
+		return spanContext, nil
+	}
+	traceId := firstMD(md, legacyTraceIdMDKey)
+	parentSpanId := firstMD(md, legacyParentSpanIdMDKey)
+	if traceId == "" || parentSpanId == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	return ExtractSpanContext(tracer, TraceContext{TraceId: traceId, SpanId: parentSpanId, Sampled: "1"})
+}
+
+func firstMD(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func spanIDFromSpanContext(spanContext opentracing.SpanContext) string {
+	switch sc := spanContext.(type) {
+	case uber.SpanContext:
+		return sc.SpanID().String()
+	case zipkinot.SpanContext:
+		return sc.ID.String()
+	case otelSpanContext:
+		if sc.IsValid() {
+			return sc.SpanID().String()
+		}
+	}
+	return ""
+}
+
+// startGrpcClientSpan 创建客户端span并注入outgoing metadata
+func startGrpcClientSpan(ctx context.Context, tracer opentracing.Tracer, method string) (context.Context, opentracing.Span) {
+	//span.kind在创建时设置 OTel bridge只在StartSpan时读取
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := tracer.StartSpan("gRPC Client "+method, opts...)
+	ext.Component.Set(span, grpcComponentName)
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := InjectGrpcMetadata(tracer, span.Context(), md); err != nil {
+		span.LogKV("event", "inject error", "error.object", err.Error())
+	}
+	//span context没有traceId时(例如noop tracer) 使用ctx中的旧版traceId 下游日志仍可关联
+	if len(md.Get(legacyTraceIdMDKey)) == 0 {
+		if tc := TraceContextFromContext(ctx); tc.TraceId != "" {
+			md.Set(legacyTraceIdMDKey, tc.TraceId)
+			if tc.SpanId != "" {
+				md.Set(legacyParentSpanIdMDKey, tc.SpanId)
+			}
+		}
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	return opentracing.ContextWithSpan(ctx, span), span
+}
+
+// startGrpcServerSpan 从incoming metadata中提取父span并创建服务端span
+func startGrpcServerSpan(ctx context.Context, tracer opentracing.Tracer, method string) (context.Context, opentracing.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var opts []opentracing.StartSpanOption
+	parent, err := ExtractGrpcMetadata(tracer, md)
+	if err == nil {
+		opts = append(opts, ext.RPCServerOption(parent))
+	} else {
+		opts = append(opts, ext.SpanKindRPCServer)
+	}
+	span := tracer.StartSpan("gRPC Server "+method, opts...)
+	ext.Component.Set(span, grpcComponentName)
+	//tracer无法还原或span没有traceId(noop tracer)时 保留调用方的traceId
+	if err != nil || traceIDFromSpanContext(span.Context()) == "" {
+		if traceId := firstMD(md, legacyTraceIdMDKey); traceId != "" {
+			ctx = ContextWithTrace(ctx, traceId)
+		}
+	}
+	return opentracing.ContextWithSpan(ctx, span), span
+}
+
+func finishGrpcSpan(span opentracing.Span, err error) {
+	if err != nil && err != io.EOF {
+		st, _ := status.FromError(err)
+		ext.Error.Set(span, true)
+		span.SetTag("grpc.code", st.Code().String())
+		span.LogKV("event", "error")
+		span.LogKV("error.kind", st.Code().String())
+		span.LogKV("error.object", err.Error())
+	}
+	span.Finish()
+}
+
+// OpenTracingUnaryClientInterceptor 创建客户端span 并通过metadata传递span context
+func OpenTracingUnaryClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := startGrpcClientSpan(ctx, tracer, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishGrpcSpan(span, err)
+		return err
+	}
+}
+
+/*
+*
+OpenTracingStreamClientInterceptor 创建客户端span
+流结束(RecvMsg返回错误或io.EOF) 非服务端流收到响应 或ctx结束时finish
+*/
+func OpenTracingStreamClientInterceptor(tracer opentracing.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := startGrpcClientSpan(ctx, tracer, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishGrpcSpan(span, err)
+			return nil, err
+		}
+		var once sync.Once
+		finish := func(err error) {
+			once.Do(func() {
+				finishGrpcSpan(span, err)
+			})
+		}
+		//调用方没读到流结束就放弃时 span在ctx取消或超时时结束 AfterFunc只在ctx结束时才启动goroutine
+		stop := context.AfterFunc(ctx, func() {
+			finish(status.FromContextError(ctx.Err()).Err())
+		})
+		return &tracedClientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: func(err error) {
+			stop()
+			finish(err)
+		}}, nil
+	}
+}
+
+type tracedClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(err error)
+}
+
+func (stream *tracedClientStream) Header() (metadata.MD, error) {
+	md, err := stream.ClientStream.Header()
+	if err != nil {
+		stream.finish(err)
+	}
+	return md, err
+}
+
+func (stream *tracedClientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	if err != nil || !stream.serverStreams {
+		stream.finish(err)
+	}
+	return err
+}
+
+// OpenTracingUnaryServerInterceptor 从metadata中提取span context 创建服务端span
+func OpenTracingUnaryServerInterceptor(tracer opentracing.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startGrpcServerSpan(ctx, tracer, info.FullMethod)
+		resp, err := handler(ctx, req)
+		finishGrpcSpan(span, err)
+		return resp, err
+	}
+}
+
+// OpenTracingStreamServerInterceptor 从metadata中提取span context 创建服务端span
+func OpenTracingStreamServerInterceptor(tracer opentracing.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startGrpcServerSpan(stream.Context(), tracer, info.FullMethod)
+		err := handler(srv, &tracedServerStream{ServerStream: stream, ctx: ctx})
+		finishGrpcSpan(span, err)
+		return err
+	}
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *tracedServerStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,292 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	uber "github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// ctxHealthServer 记录服务端收到的context
+type ctxHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	ctx chan context.Context
+}
+
+func (server *ctxHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	server.ctx <- ctx
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (server *ctxHealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	server.ctx <- stream.Context()
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func newJaegerTracer(service string) (opentracing.Tracer, *uber.InMemoryReporter) {
+	reporter := uber.NewInMemoryReporter()
+	tracer, _ := uber.NewTracer(service, uber.NewConstSampler(true), reporter)
+	return tracer, reporter
+}
+
+func newGrpcTestClient(t *testing.T, serverTracer opentracing.Tracer, clientOpts ...grpc.DialOption) (*ctxHealthServer, grpc_health_v1.HealthClient) {
+	server := &ctxHealthServer{ctx: make(chan context.Context, 1)}
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(OpenTracingUnaryServerInterceptor(serverTracer)),
+		grpc.ChainStreamInterceptor(OpenTracingStreamServerInterceptor(serverTracer)),
+	)
+	grpc_health_v1.RegisterHealthServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	opts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, clientOpts...)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestGrpcInterceptorParentChild(t *testing.T) {
+	clientTracer, clientReporter := newJaegerTracer("client")
+	serverTracer, serverReporter := newJaegerTracer("server")
+	server, client := newGrpcTestClient(t, serverTracer,
+		grpc.WithChainUnaryInterceptor(OpenTracingUnaryClientInterceptor(clientTracer)),
+		grpc.WithChainStreamInterceptor(OpenTracingStreamClientInterceptor(clientTracer)),
+	)
+
+	root := clientTracer.StartSpan("http")
+	ctx := opentracing.ContextWithSpan(context.Background(), root)
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx := <-server.ctx
+	root.Finish()
+
+	rootCtx := root.Context().(uber.SpanContext)
+	if got := TraceIDFromContext(serverCtx); got != rootCtx.TraceID().String() {
+		t.Errorf("server traceId = %s, want %s", got, rootCtx.TraceID())
+	}
+	md, _ := metadata.FromIncomingContext(serverCtx)
+	if got := md.Get(TraceIdKey); len(got) != 1 || got[0] != rootCtx.TraceID().String() {
+		t.Errorf("legacy TraceId metadata = %v", got)
+	}
+
+	clientSpans := clientReporter.GetSpans()
+	serverSpans := serverReporter.GetSpans()
+	if len(clientSpans) != 2 || len(serverSpans) != 1 {
+		t.Fatalf("spans client=%d server=%d", len(clientSpans), len(serverSpans))
+	}
+	clientSpan := clientSpans[0].(*uber.Span)
+	serverSpan := serverSpans[0].(*uber.Span)
+	if clientSpan.OperationName() != "gRPC Client /grpc.health.v1.Health/Check" {
+		t.Errorf("client operation = %s", clientSpan.OperationName())
+	}
+	if serverSpan.OperationName() != "gRPC Server /grpc.health.v1.Health/Check" {
+		t.Errorf("server operation = %s", serverSpan.OperationName())
+	}
+	if clientSpan.SpanContext().ParentID() != rootCtx.SpanID() {
+		t.Errorf("client span parent = %s, want %s", clientSpan.SpanContext().ParentID(), rootCtx.SpanID())
+	}
+	if serverSpan.SpanContext().ParentID() != clientSpan.SpanContext().SpanID() {
+		t.Errorf("server span parent = %s, want %s", serverSpan.SpanContext().ParentID(), clientSpan.SpanContext().SpanID())
+	}
+	if got := md.Get(ParentSpanIDKey); len(got) != 1 || got[0] != clientSpan.SpanContext().SpanID().String() {
+		t.Errorf("legacy ParentSpanId metadata = %v", got)
+	}
+
+	//stream
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	<-server.ctx
+	if _, err = stream.Recv(); err == nil {
+		t.Fatal("stream should end")
+	}
+	if n := len(clientReporter.GetSpans()); n != 3 {
+		t.Errorf("client spans after stream = %d", n)
+	}
+	watchSpan := serverReporter.GetSpans()[1].(*uber.Span)
+	if watchSpan.OperationName() != "gRPC Server /grpc.health.v1.Health/Watch" {
+		t.Errorf("watch operation = %s", watchSpan.OperationName())
+	}
+}
+
+func TestGrpcInterceptorLegacyFallback(t *testing.T) {
+	serverTracer, serverReporter := newJaegerTracer("server")
+	server, client := newGrpcTestClient(t, serverTracer)
+
+	//未接入opentracing的调用方 只传递TraceId ParentSpanId
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		TraceIdKey, "5af7183fb1d4cf5f",
+		ParentSpanIDKey, "6b221d5bc9e6496c",
+	)
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx := <-server.ctx
+	if got := TraceIDFromContext(serverCtx); got != "5af7183fb1d4cf5f" {
+		t.Errorf("traceId = %s", got)
+	}
+	serverSpan := serverReporter.GetSpans()[0].(*uber.Span)
+	if got := serverSpan.SpanContext().ParentID().String(); got != "6b221d5bc9e6496c" {
+		t.Errorf("server span parent = %s", got)
+	}
+
+	//无法按tracer格式解析的TraceId 保留在context中
+	ctx = metadata.AppendToOutgoingContext(context.Background(), TraceIdKey, "not-a-hex-id", ParentSpanIDKey, "1")
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx = <-server.ctx
+	if got, _ := serverCtx.Value(TraceIdKey).(string); got != "not-a-hex-id" {
+		t.Errorf("context traceId = %s", got)
+	}
+}
+
+func TestGrpcOutgoingHeader(t *testing.T) {
+	tracer, _ := newJaegerTracer("client")
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	span := tracer.StartSpan("http")
+	defer span.Finish()
+	header := metadata.MD{}
+	GrpcOutgoingHeader()(opentracing.ContextWithSpan(context.Background(), span), header)
+	spanContext := span.Context().(uber.SpanContext)
+	if got := header.Get(uber.TraceContextHeaderName); len(got) != 1 {
+		t.Errorf("uber-trace-id = %v", got)
+	}
+	if got := header.Get(TraceIdKey); len(got) != 1 || got[0] != spanContext.TraceID().String() {
+		t.Errorf("TraceId = %v", got)
+	}
+
+	//没有span时使用context中的旧版字段
+	header = metadata.MD{}
+	ctx := context.WithValue(ContextWithTrace(context.Background(), "t1"), SpanIdKey, "s1")
+	GrpcOutgoingHeader()(ctx, header)
+	if got := header.Get(ParentSpanIDKey); len(got) != 1 || got[0] != "s1" {
+		t.Errorf("ParentSpanId = %v", got)
+	}
+	if got := header.Get(TraceIdKey); len(got) != 1 || got[0] != "t1" {
+		t.Errorf("TraceId = %v", got)
+	}
+}
+
+func TestGrpcInterceptorNoopTracer(t *testing.T) {
+	tracer := opentracing.NoopTracer{}
+	server, client := newGrpcTestClient(t, tracer,
+		grpc.WithChainUnaryInterceptor(OpenTracingUnaryClientInterceptor(tracer)),
+	)
+	//noop tracer不产生traceId 使用ctx中的旧版traceId
+	ctx := ContextWithTraceContext(context.Background(), TraceContext{TraceId: "t1", SpanId: "s1"})
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx := <-server.ctx
+	md, _ := metadata.FromIncomingContext(serverCtx)
+	if got := md.Get(TraceIdKey); len(got) != 1 || got[0] != "t1" {
+		t.Errorf("legacy TraceId metadata = %v", got)
+	}
+	if got := md.Get(ParentSpanIDKey); len(got) != 1 || got[0] != "s1" {
+		t.Errorf("legacy ParentSpanId metadata = %v", got)
+	}
+	if got := TraceIDFromContext(serverCtx); got != "t1" {
+		t.Errorf("server traceId = %s", got)
+	}
+}
+
+// recvClientStream RecvMsg按顺序返回errs 之后阻塞到ctx结束
+type recvClientStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	errs []error
+}
+
+func (stream *recvClientStream) RecvMsg(m interface{}) error {
+	if len(stream.errs) == 0 {
+		<-stream.ctx.Done()
+		return stream.ctx.Err()
+	}
+	err := stream.errs[0]
+	stream.errs = stream.errs[1:]
+	return err
+}
+
+func TestGrpcStreamClientSpanFinish(t *testing.T) {
+	tracer, reporter := newJaegerTracer("client")
+	interceptor := OpenTracingStreamClientInterceptor(tracer)
+	open := func(ctx context.Context, desc *grpc.StreamDesc, errs ...error) grpc.ClientStream {
+		stream, err := interceptor(ctx, desc, nil, "/test.Stream/Call", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &recvClientStream{ctx: ctx, errs: errs}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+
+	//客户端流 收到响应即结束
+	stream := open(context.Background(), &grpc.StreamDesc{ClientStreams: true}, nil)
+	if err := stream.RecvMsg(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reporter.GetSpans()); n != 1 {
+		t.Fatalf("spans after client stream = %d", n)
+	}
+
+	//服务端流 收到消息不结束 io.EOF时结束
+	stream = open(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, io.EOF)
+	_ = stream.RecvMsg(nil)
+	if n := len(reporter.GetSpans()); n != 1 {
+		t.Fatalf("spans after first message = %d", n)
+	}
+	_ = stream.RecvMsg(nil)
+	if n := len(reporter.GetSpans()); n != 2 {
+		t.Fatalf("spans after io.EOF = %d", n)
+	}
+
+	//未结束的流不常驻goroutine
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		open(context.Background(), &grpc.StreamDesc{ServerStreams: true})
+	}
+	if n := runtime.NumGoroutine() - goroutines; n > 10 {
+		t.Fatalf("goroutines per open stream = %d", n)
+	}
+
+	//未读到结束就放弃的流 ctx结束时结束
+	ctx, cancel := context.WithCancel(context.Background())
+	open(ctx, &grpc.StreamDesc{ServerStreams: true})
+	cancel()
+	for i := 0; len(reporter.GetSpans()) != 3; i++ {
+		if i > 100 {
+			t.Fatal("span not finished after cancel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	span := reporter.GetSpans()[2].(*uber.Span)
+	if got := span.Tags()["grpc.code"]; got != "Canceled" {
+		t.Errorf("grpc.code = %v", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/uber/jaeger-client-go"
 	uber "github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 var logger LogInterface = DefaultLogger{}
@@ -58,6 +59,10 @@ func InitJaeger(traceCfg Config) (io.Closer, error) {
 func TraceIDFromContext(ctx context.Context) string {
 	span := opentracing.SpanFromContext(ctx)
 	if span == nil {
+		//直接使用OTel API创建的span
+		if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+			return sc.TraceID().String()
+		}
 		val := ctx.Value(TraceIdKey)
 		if val == nil {
 			return ""
@@ -65,11 +70,26 @@ func TraceIDFromContext(ctx context.Context) string {
 			return val.(string)
 		}
 	}
-	if u, ok := span.Context().(uber.SpanContext); ok {
-		return u.TraceID().String()
+	if traceId := traceIDFromSpanContext(span.Context()); traceId != "" {
+		return traceId
+	}
+	//未知的tracer实现(如mocktracer) 使用context中设置的traceId
+	if val, ok := ctx.Value(TraceIdKey).(string); ok {
+		return val
 	}
-	if u, ok := span.Context().(zipkinot.SpanContext); ok {
-		return u.TraceID.String()
+	return ""
+}
+
+func traceIDFromSpanContext(spanContext opentracing.SpanContext) string {
+	switch sc := spanContext.(type) {
+	case uber.SpanContext:
+		return sc.TraceID().String()
+	case zipkinot.SpanContext:
+		return sc.TraceID.String()
+	case otelSpanContext:
+		if sc.IsValid() {
+			return sc.TraceID().String()
+		}
 	}
 	return ""
 }
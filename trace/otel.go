@@ -0,0 +1,102 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const defaultOTelEndpoint = "127.0.0.1:4317"
+
+type OTelConfig struct {
+	Service string
+	//OTLP gRPC collector地址 默认127.0.0.1:4317 本地collector不使用TLS
+	Endpoint string
+	//采样比例 默认0.1 与InitJaeger的probabilistic一致 有父span时跟随父span
+	SamplerParam float64
+	//附加到resource的属性 例如 deployment.environment
+	Attributes map[string]string
+	Logger     LogInterface
+	//为nil时使用OTLP gRPC exporter
+	Exporter sdktrace.SpanExporter
+}
+
+/*
+*
+InitOTel 初始化OpenTelemetry TracerProvider 通过OTLP导出到collector
+同时设置OpenTracing bridge为全局tracer opentracing.StartSpanFromContext创建的span由OTel导出
+span context按W3C traceparent传递
+*/
+func InitOTel(traceCfg OTelConfig) (io.Closer, error) {
+	if traceCfg.Endpoint == "" {
+		traceCfg.Endpoint = defaultOTelEndpoint
+	}
+	if traceCfg.SamplerParam == 0 {
+		traceCfg.SamplerParam = 0.1
+	}
+	if traceCfg.Logger != nil {
+		logger = traceCfg.Logger
+	}
+	exporter := traceCfg.Exporter
+	if exporter == nil {
+		var err error
+		exporter, err = otlptracegrpc.New(context.Background(),
+			otlptracegrpc.WithEndpoint(traceCfg.Endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", traceCfg.Service)}
+	for key, value := range traceCfg.Attributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(traceCfg.SamplerParam))),
+	)
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	bridgeTracer, wrapperProvider := otelbridge.NewTracerPair(provider.Tracer(traceCfg.Service))
+	bridgeTracer.SetTextMapPropagator(propagator)
+	bridgeTracer.SetWarningHandler(func(msg string) {
+		logger.Warn(context.Background(), "OpenTracing bridge:", msg)
+	})
+	otel.SetTracerProvider(wrapperProvider)
+	otel.SetTextMapPropagator(propagator)
+	opentracing.SetGlobalTracer(bridgeTracer)
+	return otelCloser{provider: provider}, nil
+}
+
+// otelCloser Close时导出缓冲中的span 最多等待5秒
+type otelCloser struct {
+	provider *sdktrace.TracerProvider
+}
+
+func (closer otelCloser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return closer.provider.Shutdown(ctx)
+}
+
+// otelSpanContext bridge的span context 内嵌了oteltrace.SpanContext
+type otelSpanContext interface {
+	TraceID() oteltrace.TraceID
+	SpanID() oteltrace.SpanID
+	IsValid() bool
+}
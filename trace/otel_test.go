@@ -0,0 +1,117 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func initTestOTel(t *testing.T) (*tracetest.InMemoryExporter, func() tracetest.SpanStubs) {
+	prevTracer := opentracing.GlobalTracer()
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	exporter := tracetest.NewInMemoryExporter()
+	closer, err := InitOTel(OTelConfig{Service: "TestOTel", SamplerParam: 1, Exporter: exporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		closer.Close()
+		opentracing.SetGlobalTracer(prevTracer)
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter, func() tracetest.SpanStubs {
+		closer.(otelCloser).provider.ForceFlush(context.Background())
+		return exporter.GetSpans()
+	}
+}
+
+func TestOTelOpenTracingBridge(t *testing.T) {
+	_, spans := initTestOTel(t)
+
+	parent, ctx := opentracing.StartSpanFromContext(context.Background(), "parent")
+	child, childCtx := opentracing.StartSpanFromContext(ctx, "child")
+	otelSpanContext := oteltrace.SpanContextFromContext(childCtx)
+	if !otelSpanContext.IsValid() {
+		t.Fatal("otel span context not set by bridge")
+	}
+	if got := TraceIDFromContext(childCtx); got != otelSpanContext.TraceID().String() {
+		t.Errorf("TraceIDFromContext = %s, want %s", got, otelSpanContext.TraceID())
+	}
+	child.Finish()
+	parent.Finish()
+
+	//直接使用OTel API
+	_, otelSpan := otel.Tracer("test").Start(context.Background(), "otel")
+	if got := TraceIDFromContext(oteltrace.ContextWithSpan(context.Background(), otelSpan)); got != otelSpan.SpanContext().TraceID().String() {
+		t.Errorf("TraceIDFromContext(otel) = %s", got)
+	}
+	otelSpan.End()
+
+	stubs := spans()
+	if len(stubs) != 3 {
+		t.Fatalf("spans = %d", len(stubs))
+	}
+	if stubs[0].Name != "child" || stubs[1].Name != "parent" {
+		t.Fatalf("span names = %s %s", stubs[0].Name, stubs[1].Name)
+	}
+	if stubs[0].Parent.SpanID() != stubs[1].SpanContext.SpanID() {
+		t.Errorf("child parent = %s, want %s", stubs[0].Parent.SpanID(), stubs[1].SpanContext.SpanID())
+	}
+	if got, _ := stubs[0].Resource.Set().Value("service.name"); got.AsString() != "TestOTel" {
+		t.Errorf("service.name = %s", got.AsString())
+	}
+}
+
+func TestOTelGrpcInterceptor(t *testing.T) {
+	_, spans := initTestOTel(t)
+	tracer := opentracing.GlobalTracer()
+	server, client := newGrpcTestClient(t, tracer,
+		grpc.WithChainUnaryInterceptor(OpenTracingUnaryClientInterceptor(tracer)),
+	)
+
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx := <-server.ctx
+	md, _ := metadata.FromIncomingContext(serverCtx)
+	if len(md.Get("traceparent")) != 1 {
+		t.Errorf("traceparent = %v", md.Get("traceparent"))
+	}
+	stubs := spans()
+	if len(stubs) != 2 {
+		t.Fatalf("spans = %d", len(stubs))
+	}
+	serverSpan, clientSpan := stubs[0], stubs[1]
+	if serverSpan.Parent.SpanID() != clientSpan.SpanContext.SpanID() {
+		t.Errorf("server parent = %s, want %s", serverSpan.Parent.SpanID(), clientSpan.SpanContext.SpanID())
+	}
+	if serverSpan.SpanKind != oteltrace.SpanKindServer || clientSpan.SpanKind != oteltrace.SpanKindClient {
+		t.Errorf("span kind = %s %s", serverSpan.SpanKind, clientSpan.SpanKind)
+	}
+	if got := TraceIDFromContext(serverCtx); got != clientSpan.SpanContext.TraceID().String() {
+		t.Errorf("server traceId = %s", got)
+	}
+
+	//旧版64位TraceId补齐后作为W3C traceparent解析
+	server, client = newGrpcTestClient(t, tracer)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		TraceIdKey, "5af7183fb1d4cf5f",
+		ParentSpanIDKey, "6b221d5bc9e6496c",
+	)
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	serverCtx = <-server.ctx
+	if got := TraceIDFromContext(serverCtx); got != "00000000000000005af7183fb1d4cf5f" {
+		t.Errorf("legacy traceId = %s", got)
+	}
+}
@@ -0,0 +1,479 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	uber "github.com/uber/jaeger-client-go"
+)
+
+// 传递格式 用于NewPropagator
+const (
+	FormatTraceContext = "tracecontext" //W3C traceparent tracestate
+	FormatB3Single     = "b3"           //B3单header b3: {traceId}-{spanId}-{sampled}-{parentSpanId}
+	FormatB3Multi      = "b3multi"      //B3多header X-B3-TraceId X-B3-SpanId ...
+	FormatLegacy       = "legacy"       //旧版header 只有traceId
+)
+
+const (
+	TraceParentHeader  = "traceparent"
+	TraceStateHeader   = "tracestate"
+	B3SingleHeader     = "b3"
+	B3TraceIdHeader    = "X-B3-TraceId"
+	B3SpanIdHeader     = "X-B3-SpanId"
+	B3ParentSpanHeader = "X-B3-ParentSpanId"
+	B3SampledHeader    = "X-B3-Sampled"
+	B3FlagsHeader      = "X-B3-Flags"
+)
+
+// LegacyExtractHeaders 旧版traceId header 按顺序读取第一个非空值
+var LegacyExtractHeaders = []string{"Trace-ID", "X-Trace-ID", "X-Request-ID", "CorralId"}
+
+// LegacyInjectHeaders 写入下游请求的旧版traceId header
+var LegacyInjectHeaders = []string{"Trace-ID"}
+
+// TraceContext 跨进程传递的链路信息
+type TraceContext struct {
+	TraceId      string
+	SpanId       string //调用方的spanId 即本服务span的父span
+	ParentSpanId string
+	Sampled      string //"1"采样 "0"不采样 "d"debug 空为未决定
+	TraceState   string //W3C tracestate 原样传递
+}
+
+// Valid 有traceId即可用于日志关联 传递span关系还需要spanId
+func (tc TraceContext) Valid() bool {
+	return tc.TraceId != ""
+}
+
+// HeaderCarrier http.Header实现了该接口
+type HeaderCarrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// Propagator 在header中读写TraceContext
+type Propagator interface {
+	Extract(carrier HeaderCarrier) (TraceContext, bool)
+	Inject(tc TraceContext, carrier HeaderCarrier)
+}
+
+// TraceContextPropagator W3C traceparent tracestate
+type TraceContextPropagator struct{}
+
+func (TraceContextPropagator) Extract(carrier HeaderCarrier) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(carrier.Get(TraceParentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, false
+	}
+	if !isHexID(parts[1], 32) || !isHexID(parts[2], 16) || len(parts[3]) != 2 {
+		return TraceContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return TraceContext{}, false
+	}
+	tc := TraceContext{
+		TraceId:    parts[1],
+		SpanId:     parts[2],
+		Sampled:    "0",
+		TraceState: carrier.Get(TraceStateHeader),
+	}
+	if flags[0]&1 == 1 {
+		tc.Sampled = "1"
+	}
+	return tc, true
+}
+
+func (TraceContextPropagator) Inject(tc TraceContext, carrier HeaderCarrier) {
+	traceId, ok := normalizeHexID(tc.TraceId, 32)
+	spanId, ok2 := normalizeHexID(tc.SpanId, 16)
+	if !ok || !ok2 {
+		return
+	}
+	carrier.Set(TraceParentHeader, "00-"+traceId+"-"+spanId+"-"+traceFlags(tc.Sampled))
+	if tc.TraceState != "" {
+		carrier.Set(TraceStateHeader, tc.TraceState)
+	}
+}
+
+// B3SinglePropagator b3: {traceId}-{spanId}-{sampled}-{parentSpanId}
+type B3SinglePropagator struct{}
+
+func (B3SinglePropagator) Extract(carrier HeaderCarrier) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(carrier.Get(B3SingleHeader)), "-")
+	//只有采样标记时没有traceId
+	if len(parts) < 2 || !isB3TraceID(parts[0]) || !isHexID(parts[1], 16) {
+		return TraceContext{}, false
+	}
+	tc := TraceContext{TraceId: parts[0], SpanId: parts[1]}
+	if len(parts) > 2 {
+		tc.Sampled = parts[2]
+	}
+	if len(parts) > 3 && isHexID(parts[3], 16) {
+		tc.ParentSpanId = parts[3]
+	}
+	return tc, true
+}
+
+func (B3SinglePropagator) Inject(tc TraceContext, carrier HeaderCarrier) {
+	traceId, ok := normalizeHexID(tc.TraceId, 16, 32)
+	spanId, ok2 := normalizeHexID(tc.SpanId, 16)
+	if !ok || !ok2 {
+		return
+	}
+	value := traceId + "-" + spanId
+	if tc.Sampled != "" {
+		value += "-" + tc.Sampled
+		if parentSpanId, ok := normalizeHexID(tc.ParentSpanId, 16); ok {
+			value += "-" + parentSpanId
+		}
+	}
+	carrier.Set(B3SingleHeader, value)
+}
+
+// B3MultiPropagator X-B3-TraceId X-B3-SpanId X-B3-ParentSpanId X-B3-Sampled X-B3-Flags
+type B3MultiPropagator struct{}
+
+func (B3MultiPropagator) Extract(carrier HeaderCarrier) (TraceContext, bool) {
+	tc := TraceContext{
+		TraceId:      carrier.Get(B3TraceIdHeader),
+		SpanId:       carrier.Get(B3SpanIdHeader),
+		ParentSpanId: carrier.Get(B3ParentSpanHeader),
+	}
+	if !isB3TraceID(tc.TraceId) || !isHexID(tc.SpanId, 16) {
+		return TraceContext{}, false
+	}
+	switch sampled := carrier.Get(B3SampledHeader); sampled {
+	case "1", "true":
+		tc.Sampled = "1"
+	case "0", "false":
+		tc.Sampled = "0"
+	}
+	if carrier.Get(B3FlagsHeader) == "1" {
+		tc.Sampled = "d"
+	}
+	return tc, true
+}
+
+func (B3MultiPropagator) Inject(tc TraceContext, carrier HeaderCarrier) {
+	traceId, ok := normalizeHexID(tc.TraceId, 16, 32)
+	spanId, ok2 := normalizeHexID(tc.SpanId, 16)
+	if !ok || !ok2 {
+		return
+	}
+	carrier.Set(B3TraceIdHeader, traceId)
+	carrier.Set(B3SpanIdHeader, spanId)
+	if parentSpanId, ok := normalizeHexID(tc.ParentSpanId, 16); ok {
+		carrier.Set(B3ParentSpanHeader, parentSpanId)
+	}
+	switch tc.Sampled {
+	case "d":
+		carrier.Set(B3FlagsHeader, "1")
+	case "0", "1":
+		carrier.Set(B3SampledHeader, tc.Sampled)
+	}
+}
+
+// LegacyPropagator 旧版header 只传递traceId 不限制格式
+type LegacyPropagator struct {
+	ExtractHeaders []string //按顺序读取第一个非空值
+	InjectHeaders  []string
+}
+
+func (propagator LegacyPropagator) Extract(carrier HeaderCarrier) (TraceContext, bool) {
+	for _, key := range propagator.ExtractHeaders {
+		if value := carrier.Get(key); value != "" {
+			return TraceContext{TraceId: value}, true
+		}
+	}
+	return TraceContext{}, false
+}
+
+func (propagator LegacyPropagator) Inject(tc TraceContext, carrier HeaderCarrier) {
+	if tc.TraceId == "" {
+		return
+	}
+	for _, key := range propagator.InjectHeaders {
+		carrier.Set(key, tc.TraceId)
+	}
+}
+
+/*
+*
+CompositePropagator Extract按extract顺序使用第一个提取成功的格式
+Inject写入inject中的全部格式
+*/
+type CompositePropagator struct {
+	extract []Propagator
+	inject  []Propagator
+}
+
+func NewCompositePropagator(extract []Propagator, inject []Propagator) *CompositePropagator {
+	return &CompositePropagator{extract: extract, inject: inject}
+}
+
+func (propagator *CompositePropagator) Extract(carrier HeaderCarrier) (TraceContext, bool) {
+	for _, p := range propagator.extract {
+		if tc, ok := p.Extract(carrier); ok {
+			return tc, true
+		}
+	}
+	return TraceContext{}, false
+}
+
+func (propagator *CompositePropagator) Inject(tc TraceContext, carrier HeaderCarrier) {
+	for _, p := range propagator.inject {
+		p.Inject(tc, carrier)
+	}
+}
+
+/*
+*
+NewPropagator 按格式名创建 便于写在配置文件中
+示例:
+
+	propagator, err := trace.NewPropagator(
+		config.GetStringSlice("config.trace.extract"), //[tracecontext, b3multi, b3, legacy]
+		config.GetStringSlice("config.trace.inject"),  //[tracecontext, legacy]
+	)
+*/
+func NewPropagator(extract []string, inject []string) (Propagator, error) {
+	extractPropagators, err := propagatorsByName(extract)
+	if err != nil {
+		return nil, err
+	}
+	injectPropagators, err := propagatorsByName(inject)
+	if err != nil {
+		return nil, err
+	}
+	return NewCompositePropagator(extractPropagators, injectPropagators), nil
+}
+
+func propagatorsByName(names []string) ([]Propagator, error) {
+	var propagators []Propagator
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case FormatTraceContext:
+			propagators = append(propagators, TraceContextPropagator{})
+		case FormatB3Single:
+			propagators = append(propagators, B3SinglePropagator{})
+		case FormatB3Multi:
+			propagators = append(propagators, B3MultiPropagator{})
+		case FormatLegacy:
+			propagators = append(propagators, LegacyPropagator{ExtractHeaders: LegacyExtractHeaders, InjectHeaders: LegacyInjectHeaders})
+		default:
+			return nil, fmt.Errorf("trace: unknown propagation format %q", name)
+		}
+	}
+	return propagators, nil
+}
+
+var (
+	propagatorMu sync.RWMutex
+	//默认读取 W3C > B3多header > B3单header > 旧版header 写入W3C B3多header和Trace-ID
+	propagator Propagator = NewCompositePropagator(
+		[]Propagator{TraceContextPropagator{}, B3MultiPropagator{}, B3SinglePropagator{}, LegacyPropagator{ExtractHeaders: LegacyExtractHeaders}},
+		[]Propagator{TraceContextPropagator{}, B3MultiPropagator{}, LegacyPropagator{InjectHeaders: LegacyInjectHeaders}},
+	)
+)
+
+// SetPropagator 设置全局Propagator 入口中间件和httpz.Client使用
+func SetPropagator(p Propagator) {
+	propagatorMu.Lock()
+	defer propagatorMu.Unlock()
+	propagator = p
+}
+
+func GetPropagator() Propagator {
+	propagatorMu.RLock()
+	defer propagatorMu.RUnlock()
+	return propagator
+}
+
+// ExtractHTTP 使用全局Propagator从请求header中提取
+func ExtractHTTP(header http.Header) (TraceContext, bool) {
+	return GetPropagator().Extract(header)
+}
+
+// InjectHTTP 将ctx中的链路信息按全局Propagator写入header
+func InjectHTTP(ctx context.Context, header http.Header) {
+	if tc := TraceContextFromContext(ctx); tc.Valid() {
+		GetPropagator().Inject(tc, header)
+	}
+}
+
+/*
+*
+ContextWithHTTP 入口中间件使用 从请求header中提取链路信息并保存到ctx
+本服务生成新的spanId 调用方的spanId作为ParentSpanId 没有链路信息时返回false
+*/
+func ContextWithHTTP(ctx context.Context, header http.Header) (context.Context, bool) {
+	tc, ok := ExtractHTTP(header)
+	if !ok {
+		return ctx, false
+	}
+	tc.ParentSpanId, tc.SpanId = tc.SpanId, NewSpanID()
+	return ContextWithTraceContext(ctx, tc), true
+}
+
+type traceContextKey struct{}
+
+/*
+*
+ContextWithTraceContext 保存入口请求的链路信息
+同时设置TraceIdKey SpanIdKey ParentSpanIDKey 兼容读取context值的旧代码
+spanId为本服务生成的spanId 调用方的spanId作为ParentSpanId
+*/
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	ctx = context.WithValue(ctx, traceContextKey{}, tc)
+	ctx = ContextWithTrace(ctx, tc.TraceId)
+	if tc.SpanId != "" {
+		ctx = context.WithValue(ctx, SpanIdKey, tc.SpanId)
+	}
+	if tc.ParentSpanId != "" {
+		ctx = context.WithValue(ctx, ParentSpanIDKey, tc.ParentSpanId)
+	}
+	return ctx
+}
+
+/*
+*
+TraceContextFromContext 当前的链路信息 用于写入下游请求
+ctx中有span时traceId spanId以span为准 否则使用ContextWithTraceContext保存的值
+*/
+func TraceContextFromContext(ctx context.Context) TraceContext {
+	tc, _ := ctx.Value(traceContextKey{}).(TraceContext)
+	if tc.TraceId == "" {
+		tc.TraceId, _ = ctx.Value(TraceIdKey).(string)
+	}
+	if tc.SpanId == "" {
+		tc.SpanId, _ = ctx.Value(SpanIdKey).(string)
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		if traceId := traceIDFromSpanContext(span.Context()); traceId != "" {
+			tc.TraceId, tc.SpanId, tc.ParentSpanId = traceId, spanIDFromSpanContext(span.Context()), ""
+			if sampled, ok := sampledFromSpanContext(span.Context()); ok {
+				tc.Sampled = sampled
+			}
+		}
+	}
+	return tc
+}
+
+/*
+*
+ExtractSpanContext 将TraceContext按jaeger(uber-trace-id) B3和W3C格式交给tracer解析
+tracer自身格式中没有span context时使用 例如上游只传递了traceparent或B3 header
+*/
+func ExtractSpanContext(tracer opentracing.Tracer, tc TraceContext) (opentracing.SpanContext, error) {
+	traceId, ok := normalizeHexID(tc.TraceId, 16, 32)
+	spanId, ok2 := normalizeHexID(tc.SpanId, 16)
+	if !ok || !ok2 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	//未决定时按采样处理 与旧版行为一致
+	sampled := "1"
+	if tc.Sampled == "0" {
+		sampled = "0"
+	}
+	w3cTraceId, _ := normalizeHexID(traceId, 32)
+	carrier := opentracing.TextMapCarrier{
+		uber.TraceContextHeaderName: traceId + ":" + spanId + ":0:" + sampled,
+		"x-b3-traceid":              traceId,
+		"x-b3-spanid":               spanId,
+		"x-b3-sampled":              sampled,
+		TraceParentHeader:           "00-" + w3cTraceId + "-" + spanId + "-" + traceFlags(sampled),
+	}
+	if tc.TraceState != "" {
+		carrier[TraceStateHeader] = tc.TraceState
+	}
+	spanContext, err := tracer.Extract(opentracing.TextMap, carrier)
+	if err != nil || spanContext == nil {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	return spanContext, nil
+}
+
+// NewTraceID 32位十六进制 符合W3C traceparent
+func NewTraceID() string {
+	return fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+}
+
+// NewSpanID 16位十六进制
+func NewSpanID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+func sampledFromSpanContext(spanContext opentracing.SpanContext) (string, bool) {
+	switch sc := spanContext.(type) {
+	case uber.SpanContext:
+		if sc.IsDebug() {
+			return "d", true
+		}
+		if sc.IsSampled() {
+			return "1", true
+		}
+		return "0", true
+	case otelSpanContext:
+		if sampled, ok := sc.(interface{ IsSampled() bool }); ok {
+			if sampled.IsSampled() {
+				return "1", true
+			}
+			return "0", true
+		}
+	}
+	return "", false
+}
+
+// isB3TraceID B3的traceId为64位或128位
+func isB3TraceID(id string) bool {
+	return isHexID(id, 16) || isHexID(id, 32)
+}
+
+// isHexID 小写十六进制且不全为0
+func isHexID(id string, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	var nonZero bool
+	for _, c := range id {
+		switch {
+		case c >= '1' && c <= '9', c >= 'a' && c <= 'f':
+			nonZero = true
+		case c == '0':
+		default:
+			return false
+		}
+	}
+	return nonZero
+}
+
+/*
+*
+normalizeHexID 十六进制id左侧补0到lengths中不小于id长度的最小值
+jaeger的TraceID.String()不补0 写入B3和W3C格式前需要补齐
+*/
+func normalizeHexID(id string, lengths ...int) (string, bool) {
+	id = strings.ToLower(id)
+	for _, length := range lengths {
+		if len(id) > 0 && len(id) <= length {
+			id = strings.Repeat("0", length-len(id)) + id
+			return id, isHexID(id, length)
+		}
+	}
+	return "", false
+}
+
+func traceFlags(sampled string) string {
+	if sampled == "0" {
+		return "00"
+	}
+	return "01"
+}
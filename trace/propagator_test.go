@@ -0,0 +1,180 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	uber "github.com/uber/jaeger-client-go"
+)
+
+func TestPropagatorExtract(t *testing.T) {
+	cases := []struct {
+		name       string
+		propagator Propagator
+		header     map[string]string
+		want       TraceContext
+		ok         bool
+	}{
+		{
+			name:       "traceparent",
+			propagator: TraceContextPropagator{},
+			header: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"tracestate":  "congo=t61rcWkgMzE",
+			},
+			want: TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: "1", TraceState: "congo=t61rcWkgMzE"},
+			ok:   true,
+		},
+		{
+			name:       "traceparent all zero",
+			propagator: TraceContextPropagator{},
+			header:     map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		},
+		{
+			name:       "b3 single",
+			propagator: B3SinglePropagator{},
+			header:     map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"},
+			want:       TraceContext{TraceId: "80f198ee56343ba864fe8b2a57d3eff7", SpanId: "e457b5a2e4d86bd1", ParentSpanId: "05e3ac9a4f6e3b90", Sampled: "1"},
+			ok:         true,
+		},
+		{
+			name:       "b3 single sampling only",
+			propagator: B3SinglePropagator{},
+			header:     map[string]string{"b3": "0"},
+		},
+		{
+			name:       "b3 multi",
+			propagator: B3MultiPropagator{},
+			header: map[string]string{
+				"X-B3-TraceId": "463ac35c9f6413ad",
+				"X-B3-SpanId":  "a2fb4a1d1a96d312",
+				"X-B3-Flags":   "1",
+			},
+			want: TraceContext{TraceId: "463ac35c9f6413ad", SpanId: "a2fb4a1d1a96d312", Sampled: "d"},
+			ok:   true,
+		},
+		{
+			name:       "legacy",
+			propagator: LegacyPropagator{ExtractHeaders: LegacyExtractHeaders},
+			header:     map[string]string{"X-Request-ID": "req-1", "CorralId": "corral"},
+			want:       TraceContext{TraceId: "req-1"},
+			ok:         true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range c.header {
+				header.Set(key, value)
+			}
+			got, ok := c.propagator.Extract(header)
+			if ok != c.ok || got != c.want {
+				t.Errorf("Extract = %+v %v, want %+v %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestPropagatorInject(t *testing.T) {
+	//jaeger的TraceID.String()不补0
+	tc := TraceContext{TraceId: "3ac35c9f6413ad", SpanId: "a2fb4a1d1a96d312", ParentSpanId: "5e3ac9a4f6e3b90", Sampled: "1"}
+	header := http.Header{}
+	propagator, err := NewPropagator(nil, []string{FormatTraceContext, FormatB3Single, FormatB3Multi, FormatLegacy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	propagator.Inject(tc, header)
+	want := map[string]string{
+		"traceparent":       "00-0000000000000000003ac35c9f6413ad-a2fb4a1d1a96d312-01",
+		"b3":                "003ac35c9f6413ad-a2fb4a1d1a96d312-1-05e3ac9a4f6e3b90",
+		"X-B3-TraceId":      "003ac35c9f6413ad",
+		"X-B3-SpanId":       "a2fb4a1d1a96d312",
+		"X-B3-ParentSpanId": "05e3ac9a4f6e3b90",
+		"X-B3-Sampled":      "1",
+		"Trace-ID":          "3ac35c9f6413ad",
+	}
+	for key, value := range want {
+		if got := header.Get(key); got != value {
+			t.Errorf("%s = %s, want %s", key, got, value)
+		}
+	}
+
+	//非十六进制的旧版traceId只写入旧版header
+	header = http.Header{}
+	propagator.Inject(TraceContext{TraceId: "req-1", SpanId: "1"}, header)
+	if len(header) != 1 || header.Get("Trace-ID") != "req-1" {
+		t.Errorf("legacy inject = %v", header)
+	}
+
+	if _, err = NewPropagator([]string{"jaeger"}, nil); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
+
+func TestPropagatorPriority(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Trace-ID", "legacy")
+	header.Set("X-B3-TraceId", "463ac35c9f6413ad")
+	header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	if tc, _ := GetPropagator().Extract(header); tc.TraceId != "463ac35c9f6413ad" {
+		t.Errorf("default priority traceId = %s", tc.TraceId)
+	}
+
+	propagator, err := NewPropagator([]string{FormatLegacy, FormatB3Multi}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetPropagator(GetPropagator())
+	SetPropagator(propagator)
+	ctx, ok := ContextWithHTTP(context.Background(), header)
+	if !ok || TraceIDFromContext(ctx) != "legacy" {
+		t.Errorf("configured priority traceId = %s", TraceIDFromContext(ctx))
+	}
+}
+
+func TestContextWithHTTP(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, ok := ContextWithHTTP(context.Background(), header)
+	if !ok {
+		t.Fatal("traceparent not extracted")
+	}
+	tc := TraceContextFromContext(ctx)
+	if tc.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.ParentSpanId != "00f067aa0ba902b7" || !isHexID(tc.SpanId, 16) {
+		t.Errorf("TraceContext = %+v", tc)
+	}
+	if ctx.Value(SpanIdKey) != tc.SpanId || ctx.Value(ParentSpanIDKey) != "00f067aa0ba902b7" {
+		t.Errorf("legacy context values not set")
+	}
+
+	//下游请求的父span为本服务的spanId
+	out := http.Header{}
+	InjectHTTP(ctx, out)
+	if got := out.Get("traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+tc.SpanId+"-01" {
+		t.Errorf("outgoing traceparent = %s", got)
+	}
+}
+
+func TestExtractSpanContext(t *testing.T) {
+	tracer, _ := newJaegerTracer("server")
+	header := http.Header{}
+	header.Set("X-B3-TraceId", "463ac35c9f6413ad")
+	header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	tc, _ := ExtractHTTP(header)
+	spanContext, err := ExtractSpanContext(tracer, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := tracer.StartSpan("child", opentracing.ChildOf(spanContext))
+	defer span.Finish()
+	sc := span.Context().(uber.SpanContext)
+	if sc.TraceID().String() != "463ac35c9f6413ad" || sc.ParentID().String() != "a2fb4a1d1a96d312" {
+		t.Errorf("span context = %s parent %s", sc.TraceID(), sc.ParentID())
+	}
+
+	if _, err = ExtractSpanContext(tracer, TraceContext{TraceId: "req-1"}); err == nil {
+		t.Error("legacy traceId without spanId should fail")
+	}
+}
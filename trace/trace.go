@@ -2,9 +2,8 @@ package trace
 
 import (
 	"context"
-	"strconv"
-	"time"
 
+	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -41,18 +40,26 @@ logger添加TraceId信息
 /*
 *
 grpc请求添加TraceId ParentSpanId信息
+ctx中有span时 同时按全局tracer的格式注入span context
 */
 func GrpcOutgoingHeader() func(context.Context, metadata.MD) {
 	return func(ctx context.Context, header metadata.MD) {
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			err := InjectGrpcMetadata(opentracing.GlobalTracer(), span.Context(), header)
+			if err != nil {
+				logger.Warn(ctx, "GrpcOutgoingHeader inject err:", err)
+			}
+			if len(header.Get(TraceIdKey)) > 0 {
+				return
+			}
+		}
 		//TraceId
-		traceId := ctx.Value(TraceIdKey)
-		if traceId != nil {
-			header.Set(TraceIdKey, traceId.(string))
+		if traceId, ok := ctx.Value(TraceIdKey).(string); ok {
+			header.Set(TraceIdKey, traceId)
 		}
 		//spanID
-		spanID := ctx.Value(SpanIdKey)
-		if spanID != nil {
-			header.Set(ParentSpanIDKey, spanID.(string))
+		if spanID, ok := ctx.Value(SpanIdKey).(string); ok {
+			header.Set(ParentSpanIDKey, spanID)
 		}
 	}
 }
@@ -61,25 +68,27 @@ func GrpcOutgoingHeader() func(context.Context, metadata.MD) {
 *
 GRPC Server接收到到请求时
 
-	将header的trace添加到context
-	创建SpanId
+	从metadata中提取span context并创建服务端span 使用全局tracer
+	没有span context时读取旧版TraceId ParentSpanId
+
+Deprecated: 使用 OpenTracingUnaryServerInterceptor 或 rpcz.NewServerApp
 */
 func GrpcServiceIncomingHeaderInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if ok {
-			traceId := md.Get(TraceIdKey)
-			if len(traceId) > 0 {
-				ctx = context.WithValue(ctx, TraceIdKey, traceId[0])
+		//全局tracer在调用时读取 InitJaeger/InitZipkin可以晚于拦截器创建
+		return OpenTracingUnaryServerInterceptor(opentracing.GlobalTracer())(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			//兼容读取context中SpanId ParentSpanId的旧代码
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				if parentSpanId := md.Get(ParentSpanIDKey); len(parentSpanId) > 0 {
+					ctx = context.WithValue(ctx, ParentSpanIDKey, parentSpanId[0])
+				}
 			}
-			parentSpanId := md.Get(ParentSpanIDKey)
-			if len(parentSpanId) > 0 {
-				ctx = context.WithValue(ctx, ParentSpanIDKey, parentSpanId[0])
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				if spanId := spanIDFromSpanContext(span.Context()); spanId != "" {
+					ctx = context.WithValue(ctx, SpanIdKey, spanId)
+				}
 			}
-		} else {
-			logger.Warn(ctx, "GrpcServiceIncomingHeaderInterceptor metadata not found")
-		}
-		ctx = context.WithValue(ctx, SpanIdKey, strconv.FormatInt(time.Now().UnixNano(), 10))
-		return handler(ctx, req)
+			return handler(ctx, req)
+		})
 	}
 }
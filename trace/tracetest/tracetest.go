@@ -0,0 +1,201 @@
+/*
+*
+Package tracetest 单元测试使用的内存tracer 记录finish的span 不依赖jaeger agent
+示例:
+
+	recorder := tracetest.Install(t)
+	conn.Get(ctx, "key")
+	recorder.AssertSpan(t, "Redis GET").HasTag("component", "redis").NoError()
+*/
+package tracetest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// Recorder 内存tracer 基于mocktracer 支持TextMap和HTTPHeaders的Inject/Extract
+type Recorder struct {
+	*mocktracer.MockTracer
+}
+
+// NewRecorder 不设置为全局tracer 用于显式传入tracer的组件 例如rpcz的拦截器
+func NewRecorder() *Recorder {
+	return &Recorder{MockTracer: mocktracer.New()}
+}
+
+// Install 设置为opentracing全局tracer 测试结束时恢复原来的全局tracer
+func Install(t testing.TB) *Recorder {
+	recorder := NewRecorder()
+	prev := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(recorder)
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(prev)
+	})
+	return recorder
+}
+
+// Spans 已finish的span 按finish顺序
+func (recorder *Recorder) Spans() []*mocktracer.MockSpan {
+	return recorder.FinishedSpans()
+}
+
+// Find 满足条件的已finish span
+func (recorder *Recorder) Find(match func(span *mocktracer.MockSpan) bool) []*mocktracer.MockSpan {
+	var spans []*mocktracer.MockSpan
+	for _, span := range recorder.FinishedSpans() {
+		if match(span) {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// SpansNamed operationName相同的已finish span
+func (recorder *Recorder) SpansNamed(operationName string) []*mocktracer.MockSpan {
+	return recorder.Find(func(span *mocktracer.MockSpan) bool {
+		return span.OperationName == operationName
+	})
+}
+
+// OperationNames 已finish span的operationName 按finish顺序
+func (recorder *Recorder) OperationNames() []string {
+	var names []string
+	for _, span := range recorder.FinishedSpans() {
+		names = append(names, span.OperationName)
+	}
+	return names
+}
+
+/*
+*
+AssertSpan 要求operationName对应的span恰好有一个 否则测试失败并停止
+operationName以*结尾时按前缀匹配 例如 "HTTP POST *"
+*/
+func (recorder *Recorder) AssertSpan(t testing.TB, operationName string) *SpanAssert {
+	t.Helper()
+	spans := recorder.Find(func(span *mocktracer.MockSpan) bool {
+		if prefix, ok := strings.CutSuffix(operationName, "*"); ok {
+			return strings.HasPrefix(span.OperationName, prefix)
+		}
+		return span.OperationName == operationName
+	})
+	if len(spans) != 1 {
+		t.Fatalf("tracetest: %d spans named %q, finished spans: %v", len(spans), operationName, recorder.OperationNames())
+		//Fatalf未停止测试时(自定义testing.TB)返回空span
+		return &SpanAssert{t: t, Span: &mocktracer.MockSpan{OperationName: operationName}}
+	}
+	return &SpanAssert{t: t, Span: spans[0]}
+}
+
+// AssertOperationNames 已finish的span名称与names相同 不要求顺序
+func (recorder *Recorder) AssertOperationNames(t testing.TB, names ...string) {
+	t.Helper()
+	got := recorder.OperationNames()
+	want := append([]string(nil), names...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+		t.Errorf("tracetest: finished spans %v, want %v", got, want)
+	}
+}
+
+// SpanAssert 单个span的断言 失败时调用t.Errorf 可以链式调用
+type SpanAssert struct {
+	t    testing.TB
+	Span *mocktracer.MockSpan
+}
+
+// HasTag tag值相同 数值按fmt格式比较 例如uint16(200)与200相同
+func (assert *SpanAssert) HasTag(key string, value interface{}) *SpanAssert {
+	assert.t.Helper()
+	got := assert.Span.Tag(key)
+	if got == nil || fmt.Sprint(got) != fmt.Sprint(value) {
+		assert.t.Errorf("tracetest: span %q tag %s = %v, want %v", assert.Span.OperationName, key, got, value)
+	}
+	return assert
+}
+
+// NoTag 没有设置该tag
+func (assert *SpanAssert) NoTag(key string) *SpanAssert {
+	assert.t.Helper()
+	if got := assert.Span.Tag(key); got != nil {
+		assert.t.Errorf("tracetest: span %q tag %s = %v, want unset", assert.Span.OperationName, key, got)
+	}
+	return assert
+}
+
+// HasError 设置了error=true
+func (assert *SpanAssert) HasError() *SpanAssert {
+	assert.t.Helper()
+	if assert.Span.Tag("error") != true {
+		assert.t.Errorf("tracetest: span %q has no error tag", assert.Span.OperationName)
+	}
+	return assert
+}
+
+// NoError 没有设置error=true
+func (assert *SpanAssert) NoError() *SpanAssert {
+	assert.t.Helper()
+	if assert.Span.Tag("error") == true {
+		assert.t.Errorf("tracetest: span %q has error tag, logs: %v", assert.Span.OperationName, assert.logFields())
+	}
+	return assert
+}
+
+// HasLog LogKV或LogFields记录过该字段 value为nil时只要求字段存在
+func (assert *SpanAssert) HasLog(key string, value interface{}) *SpanAssert {
+	assert.t.Helper()
+	for _, record := range assert.Span.Logs() {
+		for _, field := range record.Fields {
+			if field.Key == key && (value == nil || field.ValueString == fmt.Sprint(value)) {
+				return assert
+			}
+		}
+	}
+	assert.t.Errorf("tracetest: span %q has no log %s=%v, logs: %v", assert.Span.OperationName, key, value, assert.logFields())
+	return assert
+}
+
+// ChildOf parent为直接父span
+func (assert *SpanAssert) ChildOf(parent *SpanAssert) *SpanAssert {
+	assert.t.Helper()
+	if assert.Span.ParentID != parent.Span.SpanContext.SpanID || assert.Span.SpanContext.TraceID != parent.Span.SpanContext.TraceID {
+		assert.t.Errorf("tracetest: span %q is not child of %q", assert.Span.OperationName, parent.Span.OperationName)
+	}
+	return assert
+}
+
+// Root 没有父span
+func (assert *SpanAssert) Root() *SpanAssert {
+	assert.t.Helper()
+	if assert.Span.ParentID != 0 {
+		assert.t.Errorf("tracetest: span %q is not root, parent %d", assert.Span.OperationName, assert.Span.ParentID)
+	}
+	return assert
+}
+
+// SameTrace 与other属于同一条链路
+func (assert *SpanAssert) SameTrace(other *SpanAssert) *SpanAssert {
+	assert.t.Helper()
+	if assert.Span.SpanContext.TraceID != other.Span.SpanContext.TraceID {
+		assert.t.Errorf("tracetest: span %q and %q are in different traces", assert.Span.OperationName, other.Span.OperationName)
+	}
+	return assert
+}
+
+func (assert *SpanAssert) logFields() []string {
+	var fields []string
+	for _, record := range assert.Span.Logs() {
+		for _, field := range record.Fields {
+			fields = append(fields, field.Key+"="+field.ValueString)
+		}
+	}
+	return fields
+}
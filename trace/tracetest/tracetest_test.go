@@ -0,0 +1,90 @@
+package tracetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// fakeT 记录断言失败 不中断测试
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestInstall(t *testing.T) {
+	prev := opentracing.GlobalTracer()
+	t.Run("install", func(t *testing.T) {
+		recorder := Install(t)
+		if opentracing.GlobalTracer() != recorder {
+			t.Fatal("recorder not installed as global tracer")
+		}
+		parent, ctx := opentracing.StartSpanFromContext(context.Background(), "parent")
+		child, _ := opentracing.StartSpanFromContext(ctx, "Redis GET")
+		ext.Component.Set(child, "redis")
+		ext.Error.Set(child, true)
+		child.LogKV("event", "error", "error.object", "timeout")
+		child.Finish()
+		parent.Finish()
+
+		recorder.AssertOperationNames(t, "Redis GET", "parent")
+		parentAssert := recorder.AssertSpan(t, "parent").Root().NoError()
+		recorder.AssertSpan(t, "Redis *").
+			ChildOf(parentAssert).
+			SameTrace(parentAssert).
+			HasTag("component", "redis").
+			HasError().
+			HasLog("error.object", "timeout").
+			HasLog("event", nil)
+	})
+	if opentracing.GlobalTracer() != prev {
+		t.Error("global tracer not restored")
+	}
+}
+
+func TestSpanAssertFailures(t *testing.T) {
+	recorder := NewRecorder()
+	span := recorder.StartSpan("HTTP GET /ping")
+	ext.HTTPStatusCode.Set(span, 200)
+	span.Finish()
+	other := recorder.StartSpan("other")
+	other.Finish()
+
+	ft := &fakeT{}
+	assert := recorder.AssertSpan(ft, "HTTP GET /ping")
+	assert.HasTag("http.status_code", 200)
+	if len(ft.errors) != 0 {
+		t.Fatalf("unexpected failures %v", ft.errors)
+	}
+	assert.HasTag("http.status_code", 500).
+		NoTag("http.status_code").
+		HasError().
+		HasLog("event", nil).
+		ChildOf(recorder.AssertSpan(ft, "other"))
+	if len(ft.errors) != 5 {
+		t.Errorf("failures = %d %v", len(ft.errors), ft.errors)
+	}
+
+	ft = &fakeT{}
+	recorder.AssertSpan(ft, "missing")
+	recorder.AssertOperationNames(ft, "other")
+	if len(ft.errors) != 2 {
+		t.Errorf("failures = %d %v", len(ft.errors), ft.errors)
+	}
+
+	recorder.Reset()
+	recorder.AssertOperationNames(t)
+}
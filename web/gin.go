@@ -3,6 +3,7 @@ package web
 import (
 	"bytes"
 	"context"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,20 +28,12 @@ var writerPool = &sync.Pool{
 func InitContext(gctx *gin.Context) {
 	requestNanoTime := time.Now()
 	requestTime := requestNanoTime.Format("2006-01-02T15:04:05.000Z07:00")
-	var traceId string
-	if xTraceID := gctx.GetHeader("X-Trace-ID"); len(xTraceID) > 0 {
-		traceId = xTraceID
-	} else {
-		if xRequestID := gctx.GetHeader("X-Request-ID"); len(xRequestID) > 0 {
-			traceId = xRequestID
-		} else {
-			traceId = strconv.FormatInt(requestNanoTime.UnixNano(), 32)
-		}
+	//按trace.GetPropagator()的顺序读取 W3C B3 X-Trace-ID X-Request-ID等 都没有时生成新的traceId
+	ctx, ok := trace.ContextWithHTTP(gctx.Request.Context(), gctx.Request.Header)
+	if !ok {
+		ctx = trace.ContextWithTraceContext(ctx, trace.TraceContext{TraceId: trace.NewTraceID(), SpanId: trace.NewSpanID()})
 	}
-	ctx := gctx.Request.Context()
-	ctx, _ = contextz.SetTraceID(ctx, traceId)
-	ctx = context.WithValue(ctx, trace.TraceIdKey, traceId)
-	ctx = context.WithValue(ctx, trace.SpanIdKey, strconv.FormatInt(requestNanoTime.UnixNano(), 10))
+	ctx, _ = contextz.SetTraceID(ctx, trace.TraceContextFromContext(ctx).TraceId)
 
 	corralId := gctx.Request.Header.Get(CorralIdKey)
 	if corralId == "" {
@@ -68,14 +61,21 @@ func RequestLog(gctx *gin.Context) {
 	corralId, _ := contextz.GetCorralID(ctx)
 	path := gctx.Request.URL.Path
 	if !strings.Contains(path, "inner") {
-		logger.WithFields(ctx, logger.Fields{
+		fields := logger.Fields{
 			"type":     "income",
 			"ts":       float64(time.Now().UnixNano()-sTime) / 1000000,
 			"corralId": corralId,
 			"method":   path,
 			"params":   string(params),
 			"result":   strings.Trim(bodylogWriter.BodyString(), "\n"),
-		}).Infof(
+		}
+		//流式响应只记录元数据
+		if bodylogWriter.Streaming() {
+			fields["result"] = "[stream]"
+			fields["stream"] = true
+			fields["size"] = gctx.Writer.Size()
+		}
+		logger.WithFields(ctx, fields).Infof(
 			"%s|%d",
 			gctx.Request.Method,
 			gctx.Writer.Status(),
@@ -83,21 +83,52 @@ func RequestLog(gctx *gin.Context) {
 	}
 }
 
+// bodyLogWriter 流式响应(text/event-stream 或调用过Flush)不记录响应体
 type bodyLogWriter struct {
 	gin.ResponseWriter
-	bodyBuf *bytes.Buffer
+	bodyBuf   *bytes.Buffer
+	streaming bool
 }
 
 func (w *bodyLogWriter) Init(writer gin.ResponseWriter) {
 	w.bodyBuf.Reset()
+	w.streaming = false
 	w.ResponseWriter = writer
 }
 
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.bodyBuf.Write(b)
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	if !w.Streaming() {
+		w.bodyBuf.Write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
 
-func (w bodyLogWriter) BodyString() string {
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	if !w.Streaming() {
+		w.bodyBuf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyLogWriter) Flush() {
+	w.streaming = true
+	w.bodyBuf.Reset()
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap 用于http.ResponseController
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyLogWriter) Streaming() bool {
+	if !w.streaming && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.streaming = true
+		w.bodyBuf.Reset()
+	}
+	return w.streaming
+}
+
+func (w *bodyLogWriter) BodyString() string {
 	return w.bodyBuf.String()
 }
@@ -3,12 +3,14 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
 	"github.com/songlma/gobase/trace"
 )
 
@@ -29,12 +31,17 @@ type Result struct {
 
 /*
 *
-绑定参数
+绑定参数并按binding tag校验
+支持V2请求格式 {"version":"V2","params":{}}
+err
+
+	参数格式错误 code为CodeParamsErr的errorz.Error
+	校验失败 *ValidationError
 */
 func ShouldBindBodyWith(ginContext *gin.Context, obj interface{}) error {
 	body, errz := GetParams(ginContext)
 	if errz != nil {
-		return errz
+		return BindError(ginContext, errz)
 	}
 	var object json.RawMessage
 	var res = Request{
@@ -42,23 +49,20 @@ func ShouldBindBodyWith(ginContext *gin.Context, obj interface{}) error {
 	}
 	err := json.Unmarshal(body, &res)
 	if err != nil {
-		return err
-	}
-	if res.Version == "" {
-
+		return BindError(ginContext, err)
 	}
 	switch res.Version {
 	case "V2":
 		if err = json.Unmarshal(object, obj); err != nil {
-			return err
+			return BindError(ginContext, err)
 		}
 	default:
 		err = json.Unmarshal(body, obj)
 		if err != nil {
-			return err
+			return BindError(ginContext, err)
 		}
 	}
-	return nil
+	return Validate(ginContext, obj)
 }
 
 func GetParams(ginCtx *gin.Context) (body []byte, err error) {
@@ -106,14 +110,22 @@ func SetApiResultSuccess(ctx context.Context, resp http.ResponseWriter, content
 	setApiResult(ctx, resp, CodeOk, "success", "操作成功", content)
 }
 
+/*
+*
+输出错误结果 code为errorz.CodeOf(err) alert为空时使用errorz.AlertOf(err)
+gin中推荐使用 Respond 会设置http状态码并隐藏错误详情
+*/
 func SetApiResultError(ctx context.Context, resp http.ResponseWriter, err error, alert string) {
-	var code = 0
-	if se, ok := err.(interface {
-		Code() int
-	}); ok {
-		code = se.Code()
+	code := errorz.CodeOf(err)
+	if alert == "" {
+		alert = errorz.AlertOf(err)
+	}
+	var content interface{}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		content = map[string]interface{}{"violations": validationErr.Violations}
 	}
-	setApiResult(ctx, resp, int64(code), err.Error(), alert, nil)
+	setApiResult(ctx, resp, int64(code), err.Error(), alert, content)
 }
 
 func setApiResult(ctx context.Context, resp http.ResponseWriter, resultCode int64, msg string, alert string, content interface{}) {
@@ -128,5 +140,6 @@ func setApiResult(ctx context.Context, resp http.ResponseWriter, resultCode int6
 
 func showApiResult(ctx context.Context, resp http.ResponseWriter, result *Result) {
 	resultBytes, _ := json.Marshal(result)
+	resp.Header().Set("Content-Type", "application/json; charset=utf-8")
 	fmt.Fprint(resp, string(resultBytes))
 }
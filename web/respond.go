@@ -0,0 +1,146 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"github.com/songlma/gobase/trace"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// MIMEProtobuf protobuf响应的Content-Type
+const MIMEProtobuf = "application/x-protobuf"
+
+const (
+	internalErrMsg   = "internal error"
+	internalErrAlert = "系统繁忙，请稍后再试"
+)
+
+func init() {
+	errorz.Register(CodeParamsErr, http.StatusBadRequest, paramsErrAlert)
+}
+
+// ShowErrorDetails 错误响应的msg返回完整错误链(包括文件位置) 默认不返回 只应在开发测试环境开启
+var ShowErrorDetails = false
+
+/*
+*
+Respond 输出web.Result
+err为nil时 code为0 content为data
+err不为nil时
+
+	http状态码 errorz.HTTPStatusOf 可通过errorz.Register注册
+	code      errorz.CodeOf 未知错误为-1
+	msg       只返回errorz的msg(未知错误返回internal error) ShowErrorDetails为true时返回完整错误链
+	alert     errorz.AlertOf 为空时使用注册的默认提示
+	content   *ValidationError 返回 {"violations":[...]}
+
+Accept为application/x-protobuf时 以protobuf编码(包括错误响应) 格式见result.proto
+示例:
+
+	func GetOrder(ginCtx *gin.Context) {
+		order, err := service.GetOrder(ginCtx.Request.Context(), ginCtx.Query("order_id"))
+		web.Respond(ginCtx, order, err)
+	}
+*/
+func Respond(ginCtx *gin.Context, data interface{}, err error) {
+	ctx := ginCtx.Request.Context()
+	result := &Result{
+		Code:    CodeOk,
+		Msg:     "success",
+		Alert:   "操作成功",
+		Content: data,
+		TraceId: trace.TraceIDFromContext(ctx),
+	}
+	status := http.StatusOK
+	if err != nil {
+		status = errorz.HTTPStatusOf(err)
+		result.Code = int64(errorz.CodeOf(err))
+		result.Content = nil
+		result.Alert = errorz.AlertOf(err)
+		if result.Alert == "" {
+			result.Alert = errorz.RegisteredAlertOf(err)
+		}
+		internal := status >= http.StatusInternalServerError
+		if internal {
+			errorLog(ctx, "Respond", err, ginCtx.Request.Method, ginCtx.Request.URL.Path)
+			if result.Alert == "" {
+				result.Alert = internalErrAlert
+			}
+		}
+		switch {
+		case ShowErrorDetails:
+			result.Msg = err.Error()
+		case internal:
+			result.Msg = internalErrMsg
+		default:
+			result.Msg = errorz.MsgOf(err)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			result.Content = map[string]interface{}{"violations": validationErr.Violations}
+		}
+	}
+	RespondResult(ginCtx, status, result)
+}
+
+// RespondResult 按Accept输出result Accept为protobuf时按result.proto编码 否则为json
+func RespondResult(ginCtx *gin.Context, status int, result *Result) {
+	if acceptProtobuf(ginCtx) {
+		body, err := marshalResultProto(result)
+		if err == nil {
+			ginCtx.Data(status, MIMEProtobuf, body)
+			return
+		}
+		errorLog(ginCtx.Request.Context(), "RespondProtobuf", err)
+	}
+	ginCtx.JSON(status, result)
+}
+
+func acceptProtobuf(ginCtx *gin.Context) bool {
+	accept := ginCtx.GetHeader("Accept")
+	return strings.Contains(accept, MIMEProtobuf) || strings.Contains(accept, "application/protobuf")
+}
+
+// marshalResultProto 按result.proto编码
+func marshalResultProto(result *Result) ([]byte, error) {
+	protoResult := &ProtoResult{
+		Code:    result.Code,
+		Msg:     result.Msg,
+		Alert:   result.Alert,
+		TraceId: result.TraceId,
+	}
+	if result.Content != nil {
+		content, err := protoContent(result.Content)
+		if err != nil {
+			return nil, err
+		}
+		if protoResult.Content, err = anypb.New(content); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Marshal(protoResult)
+}
+
+// protoContent 不是proto.Message的content按json转为google.protobuf.Value
+func protoContent(content interface{}) (proto.Message, error) {
+	if message, ok := content.(proto.Message); ok {
+		return message, nil
+	}
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err = protojson.Unmarshal(b, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songlma/gobase/errorz"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const codeOrderNotFound = 20404
+
+func init() {
+	errorz.Register(codeOrderNotFound, http.StatusNotFound, "订单不存在")
+}
+
+func respond(accept string, data interface{}, err error) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		ginCtx.Request.Header.Set("Accept", accept)
+	}
+	Respond(ginCtx, data, err)
+	return w
+}
+
+func decodeResult(t *testing.T, w *httptest.ResponseRecorder) Result {
+	t.Helper()
+	var result Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRespond(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   int64
+		msg    string
+		alert  string
+	}{
+		{"registered", errorz.New(codeOrderNotFound, "order 1 not found"), http.StatusNotFound, codeOrderNotFound, "order 1 not found", "订单不存在"},
+		{"wrapped alert", errorz.Wrap(errorz.New(codeOrderNotFound, "not found", errorz.WithAlert("订单已删除")), 30000, "query order"), http.StatusNotFound, 30000, "query order", "订单已删除"},
+		{"unregistered", errorz.New(30001, "balance not enough", errorz.WithAlert("余额不足")), http.StatusOK, 30001, "balance not enough", "余额不足"},
+		{"internal", errors.New("dial tcp 10.0.0.1:3306: connection refused"), http.StatusInternalServerError, -1, internalErrMsg, internalErrAlert},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := respond("", nil, c.err)
+			result := decodeResult(t, w)
+			if w.Code != c.status || result.Code != c.code || result.Msg != c.msg || result.Alert != c.alert {
+				t.Fatalf("status = %d result = %+v", w.Code, result)
+			}
+		})
+	}
+
+	ShowErrorDetails = true
+	err := errorz.New(codeOrderNotFound, "order 1 not found")
+	if result := decodeResult(t, respond("", nil, err)); result.Msg != err.Error() {
+		t.Fatalf("details msg = %s", result.Msg)
+	}
+	ShowErrorDetails = false
+
+	w := respond("", map[string]int{"id": 1}, nil)
+	result := decodeResult(t, w)
+	if w.Code != http.StatusOK || result.Code != CodeOk || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("status = %d result = %+v", w.Code, result)
+	}
+}
+
+func decodeProtoResult(t *testing.T, w *httptest.ResponseRecorder) *ProtoResult {
+	t.Helper()
+	if w.Header().Get("Content-Type") != MIMEProtobuf {
+		t.Fatalf("content type = %s", w.Header().Get("Content-Type"))
+	}
+	result := &ProtoResult{}
+	if err := proto.Unmarshal(w.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRespond_Protobuf(t *testing.T) {
+	result := decodeProtoResult(t, respond(MIMEProtobuf, wrapperspb.String("hello"), nil))
+	value := &wrapperspb.StringValue{}
+	if err := result.GetContent().UnmarshalTo(value); err != nil || value.GetValue() != "hello" || result.GetMsg() != "success" {
+		t.Fatalf("value = %v result = %v err = %v", value, result, err)
+	}
+
+	//错误响应同样以protobuf编码
+	w := respond(MIMEProtobuf, nil, errorz.New(codeOrderNotFound, "order 1 not found"))
+	result = decodeProtoResult(t, w)
+	if w.Code != http.StatusNotFound || result.GetCode() != codeOrderNotFound || result.GetAlert() != "订单不存在" || result.GetContent() != nil {
+		t.Fatalf("status = %d result = %v", w.Code, result)
+	}
+
+	//非proto.Message的content以google.protobuf.Value表示
+	result = decodeProtoResult(t, respond(MIMEProtobuf, nil, &ValidationError{
+		err:        errorz.New(CodeParamsErr, "invalid params"),
+		Violations: []FieldViolation{{Field: "goods_id", Tag: "required", Message: "goods_id为必填字段"}},
+	}))
+	content := &structpb.Value{}
+	if err := result.GetContent().UnmarshalTo(content); err != nil {
+		t.Fatal(err)
+	}
+	violations := content.GetStructValue().GetFields()["violations"].GetListValue().GetValues()
+	if result.GetCode() != CodeParamsErr || len(violations) != 1 || violations[0].GetStructValue().GetFields()["field"].GetStringValue() != "goods_id" {
+		t.Fatalf("result = %v", result)
+	}
+}
@@ -0,0 +1,167 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: web/result.proto
+
+// 生成(在仓库根目录): protoc --go_out=. --go_opt=paths=source_relative web/result.proto
+
+package web
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ProtoResult web.Respond 在 Accept: application/x-protobuf 时的响应格式
+// 字段含义与json的web.Result相同 content为业务响应
+// content不是proto.Message时(包括校验失败的violations) 以google.protobuf.Value表示
+type ProtoResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int64                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Msg           string                 `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	Content       *anypb.Any             `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Alert         string                 `protobuf:"bytes,4,opt,name=alert,proto3" json:"alert,omitempty"`
+	TraceId       string                 `protobuf:"bytes,5,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProtoResult) Reset() {
+	*x = ProtoResult{}
+	mi := &file_web_result_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProtoResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtoResult) ProtoMessage() {}
+
+func (x *ProtoResult) ProtoReflect() protoreflect.Message {
+	mi := &file_web_result_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtoResult.ProtoReflect.Descriptor instead.
+func (*ProtoResult) Descriptor() ([]byte, []int) {
+	return file_web_result_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ProtoResult) GetCode() int64 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ProtoResult) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *ProtoResult) GetContent() *anypb.Any {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *ProtoResult) GetAlert() string {
+	if x != nil {
+		return x.Alert
+	}
+	return ""
+}
+
+func (x *ProtoResult) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+var File_web_result_proto protoreflect.FileDescriptor
+
+const file_web_result_proto_rawDesc = "" +
+	"\n" +
+	"\x10web/result.proto\x12\n" +
+	"gobase.web\x1a\x19google/protobuf/any.proto\"\x94\x01\n" +
+	"\vProtoResult\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x03R\x04code\x12\x10\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\x12.\n" +
+	"\acontent\x18\x03 \x01(\v2\x14.google.protobuf.AnyR\acontent\x12\x14\n" +
+	"\x05alert\x18\x04 \x01(\tR\x05alert\x12\x19\n" +
+	"\btrace_id\x18\x05 \x01(\tR\atraceIdB\x1fZ\x1dgithub.com/songlma/gobase/webb\x06proto3"
+
+var (
+	file_web_result_proto_rawDescOnce sync.Once
+	file_web_result_proto_rawDescData []byte
+)
+
+func file_web_result_proto_rawDescGZIP() []byte {
+	file_web_result_proto_rawDescOnce.Do(func() {
+		file_web_result_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_web_result_proto_rawDesc), len(file_web_result_proto_rawDesc)))
+	})
+	return file_web_result_proto_rawDescData
+}
+
+var file_web_result_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_web_result_proto_goTypes = []any{
+	(*ProtoResult)(nil), // 0: gobase.web.ProtoResult
+	(*anypb.Any)(nil),   // 1: google.protobuf.Any
+}
+var file_web_result_proto_depIdxs = []int32{
+	1, // 0: gobase.web.ProtoResult.content:type_name -> google.protobuf.Any
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_web_result_proto_init() }
+func file_web_result_proto_init() {
+	if File_web_result_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_web_result_proto_rawDesc), len(file_web_result_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_web_result_proto_goTypes,
+		DependencyIndexes: file_web_result_proto_depIdxs,
+		MessageInfos:      file_web_result_proto_msgTypes,
+	}.Build()
+	File_web_result_proto = out.File
+	file_web_result_proto_goTypes = nil
+	file_web_result_proto_depIdxs = nil
+}
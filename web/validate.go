@@ -0,0 +1,253 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"github.com/songlma/gobase/errorz"
+)
+
+// CodeParamsErr 参数错误
+const CodeParamsErr = http.StatusBadRequest
+
+// DefaultValidationLocale 请求没有Accept-Language时校验信息使用的语言
+var DefaultValidationLocale = "zh"
+
+const paramsErrAlert = "请求参数错误"
+
+// 与gin binding.Form一致
+const defaultMultipartMemory = 32 << 20
+
+// FieldViolation 单个字段的校验失败信息
+type FieldViolation struct {
+	Field   string `json:"field"`           //字段名 优先使用json tag 嵌套字段以.分隔
+	Tag     string `json:"tag"`             //失败的校验规则 例如 required max
+	Param   string `json:"param,omitempty"` //校验规则参数 例如 max=10 中的10
+	Message string `json:"message"`         //按语言翻译后的提示
+}
+
+/*
+*
+ValidationError 参数校验失败 code为CodeParamsErr
+SetApiResultError 会将 Violations 放在content.violations 中返回
+*/
+type ValidationError struct {
+	err        errorz.Error
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+func (e *ValidationError) Cause() error {
+	return e.err.Cause()
+}
+
+func (e *ValidationError) Code() int {
+	return e.err.Code()
+}
+
+func (e *ValidationError) Alert() string {
+	return e.err.Alert()
+}
+
+var (
+	validatorOnce sync.Once
+	validate      *validator.Validate
+	translators   *ut.UniversalTranslator
+)
+
+/*
+*
+initValidator web包独立的校验器 不修改gin的binding.Validator
+校验规则使用binding tag 字段名使用json tag 并注册中英文翻译
+*/
+func initValidator() {
+	validatorOnce.Do(func() {
+		translators = ut.New(en.New(), en.New(), zh.New())
+		validate = validator.New()
+		validate.SetTagName("binding")
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+		if trans, found := translators.GetTranslator("en"); found {
+			_ = enTranslations.RegisterDefaultTranslations(validate, trans)
+		}
+		if trans, found := translators.GetTranslator("zh"); found {
+			_ = zhTranslations.RegisterDefaultTranslations(validate, trans)
+		}
+	})
+}
+
+// ValidatorEngine web绑定使用的校验器 可注册自定义校验规则
+func ValidatorEngine() *validator.Validate {
+	initValidator()
+	return validate
+}
+
+// validateStruct 与gin默认校验器一致 指针取值 slice逐个校验 非结构体不校验
+func validateStruct(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return validate.Struct(value.Interface())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := validateStruct(value.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requestTranslator 按Accept-Language选择翻译
+func requestTranslator(ginCtx *gin.Context) ut.Translator {
+	var locales []string
+	if ginCtx != nil {
+		for _, item := range strings.Split(ginCtx.GetHeader("Accept-Language"), ",") {
+			locale := strings.TrimSpace(strings.SplitN(item, ";", 2)[0])
+			if locale == "" {
+				continue
+			}
+			locales = append(locales, locale, strings.SplitN(locale, "-", 2)[0])
+		}
+	}
+	locales = append(locales, DefaultValidationLocale)
+	trans, _ := translators.FindTranslator(locales...)
+	return trans
+}
+
+/*
+*
+将绑定或校验错误转为errorz.Error
+validator校验失败返回 *ValidationError 其他错误(json格式错误等)返回code为CodeParamsErr的errorz.Error
+*/
+func BindError(ginCtx *gin.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	initValidator()
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return errorz.Wrap(err, CodeParamsErr, "invalid params: "+err.Error(), errorz.WithAlert(paramsErrAlert))
+	}
+	trans := requestTranslator(ginCtx)
+	violations := make([]FieldViolation, 0, len(validationErrors))
+	var messages []string
+	for _, fieldError := range validationErrors {
+		violation := FieldViolation{
+			Field:   violationField(fieldError),
+			Tag:     fieldError.Tag(),
+			Param:   fieldError.Param(),
+			Message: fieldError.Translate(trans),
+		}
+		violations = append(violations, violation)
+		messages = append(messages, violation.Field+":"+violation.Tag)
+	}
+	alert := paramsErrAlert
+	if len(violations) > 0 {
+		alert = violations[0].Message
+	}
+	return &ValidationError{
+		err:        errorz.Wrap(err, CodeParamsErr, "invalid params: "+strings.Join(messages, ","), errorz.WithAlert(alert)),
+		Violations: violations,
+	}
+}
+
+// violationField 去掉顶层结构体名 例如 CreateReq.items[0].name -> items[0].name
+func violationField(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldError.Field()
+}
+
+// Validate 按binding tag校验结构体
+func Validate(ginCtx *gin.Context, obj interface{}) error {
+	initValidator()
+	return BindError(ginCtx, validateStruct(obj))
+}
+
+/*
+*
+绑定json请求体并校验 支持V2请求格式 {"version":"V2","params":{}}
+示例:
+
+	type CreateOrderReq struct {
+		GoodsId int64  `json:"goods_id" binding:"required,gt=0"`
+		Remark  string `json:"remark" binding:"max=200"`
+	}
+	var req CreateOrderReq
+	if err := web.ShouldBindJSON(ginCtx, &req); err != nil {
+		web.SetApiResultError(ctx, ginCtx.Writer, err, errorz.AlertOf(err))
+		return
+	}
+*/
+func ShouldBindJSON(ginCtx *gin.Context, obj interface{}) error {
+	return ShouldBindBodyWith(ginCtx, obj)
+}
+
+// ShouldBindQuery 绑定url参数并校验 字段使用form tag
+func ShouldBindQuery(ginCtx *gin.Context, obj interface{}) error {
+	if err := binding.MapFormWithTag(obj, ginCtx.Request.URL.Query(), "form"); err != nil {
+		return BindError(ginCtx, err)
+	}
+	return Validate(ginCtx, obj)
+}
+
+// ShouldBindForm 绑定表单(包括url参数)并校验 字段使用form tag 文件请使用ginCtx.FormFile
+func ShouldBindForm(ginCtx *gin.Context, obj interface{}) error {
+	req := ginCtx.Request
+	if err := req.ParseForm(); err != nil {
+		return BindError(ginCtx, err)
+	}
+	if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return BindError(ginCtx, err)
+	}
+	if err := binding.MapFormWithTag(obj, req.Form, "form"); err != nil {
+		return BindError(ginCtx, err)
+	}
+	return Validate(ginCtx, obj)
+}
+
+// ShouldBind 按Content-Type选择绑定方式 GET和DELETE请求绑定url参数
+func ShouldBind(ginCtx *gin.Context, obj interface{}) error {
+	method := ginCtx.Request.Method
+	if method == http.MethodGet || method == http.MethodDelete {
+		return ShouldBindQuery(ginCtx, obj)
+	}
+	if strings.Contains(ginCtx.ContentType(), "json") {
+		return ShouldBindJSON(ginCtx, obj)
+	}
+	return ShouldBindForm(ginCtx, obj)
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/songlma/gobase/errorz"
+)
+
+type createOrderReq struct {
+	GoodsId int64  `json:"goods_id" form:"goods_id" binding:"required,gt=0"`
+	Remark  string `json:"remark" form:"remark" binding:"max=5"`
+}
+
+func newTestContext(method, target, contentType, body, language string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		ginCtx.Request.Header.Set("Content-Type", contentType)
+	}
+	if language != "" {
+		ginCtx.Request.Header.Set("Accept-Language", language)
+	}
+	return ginCtx
+}
+
+func TestShouldBind_Validation(t *testing.T) {
+	cases := []struct {
+		name     string
+		ginCtx   *gin.Context
+		message  string
+		wantCode int
+	}{
+		{"json", newTestContext(http.MethodPost, "/", "application/json", `{"remark":"too long"}`, "en-US,en;q=0.9"), "goods_id is a required field", CodeParamsErr},
+		{"v2", newTestContext(http.MethodPost, "/", "application/json", `{"version":"V2","params":{"goods_id":-1}}`, ""), "goods_id必须大于0", CodeParamsErr},
+		{"form", newTestContext(http.MethodPost, "/", "application/x-www-form-urlencoded", "remark=abc", "en"), "goods_id is a required field", CodeParamsErr},
+		{"query", newTestContext(http.MethodGet, "/?goods_id=0", "", "", "en"), "goods_id is a required field", CodeParamsErr},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req createOrderReq
+			err := ShouldBind(c.ginCtx, &req)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("err = %v, want *ValidationError", err)
+			}
+			if errorz.CodeOf(err) != c.wantCode || validationErr.Violations[0].Field != "goods_id" {
+				t.Fatalf("code = %d violations = %+v", errorz.CodeOf(err), validationErr.Violations)
+			}
+			if validationErr.Violations[0].Message != c.message || errorz.AlertOf(err) != c.message {
+				t.Fatalf("message = %q alert = %q", validationErr.Violations[0].Message, errorz.AlertOf(err))
+			}
+		})
+	}
+
+	var req createOrderReq
+	err := ShouldBindJSON(newTestContext(http.MethodPost, "/", "application/json", `{"goods_id":`, ""), &req)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) || errorz.CodeOf(err) != CodeParamsErr {
+		t.Fatalf("syntax err = %v", err)
+	}
+	if err = ShouldBindJSON(newTestContext(http.MethodPost, "/", "application/json", `{"goods_id":1,"remark":"ok"}`, ""), &req); err != nil || req.GoodsId != 1 {
+		t.Fatalf("err = %v req = %+v", err, req)
+	}
+}
+
+func TestValidate_GinValidatorUntouched(t *testing.T) {
+	if err := Validate(nil, &createOrderReq{}); err == nil {
+		t.Fatal("want validation error")
+	}
+	//gin全局校验器仍使用结构体字段名
+	var validationErrors validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(&createOrderReq{}); !errors.As(err, &validationErrors) || validationErrors[0].Field() != "GoodsId" {
+		t.Fatalf("gin validator err = %v", err)
+	}
+}
+
+func TestSetApiResultError_Violations(t *testing.T) {
+	ginCtx := newTestContext(http.MethodPost, "/", "application/json", `{}`, "en")
+	var req createOrderReq
+	err := ShouldBindJSON(ginCtx, &req)
+	w := httptest.NewRecorder()
+	SetApiResultError(ginCtx.Request.Context(), w, err, errorz.AlertOf(err))
+	if !strings.Contains(w.Body.String(), `"violations":[{"field":"goods_id","tag":"required"`) {
+		t.Fatalf("body = %s", w.Body)
+	}
+}